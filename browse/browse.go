@@ -0,0 +1,116 @@
+// Package browse is a full-screen terminal browser of the strips in the
+// database: it searches as the user types, and shows the strips found.
+package browse
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/lavagetto/xkcli/render"
+)
+
+// Run shows the browser on the terminal until the user quits. If opts.Open
+// or opts.Copy are nil, the system browser and the clipboard of the terminal
+// are used.
+func Run(m *Model, in, out *os.File) error {
+	restore, err := makeRaw(in)
+	if err != nil {
+		return fmt.Errorf("can't use the terminal: %s", err)
+	}
+	defer restore()
+	if m.opts.Open == nil {
+		m.opts.Open = openURL
+	}
+	if m.opts.Copy == nil {
+		m.opts.Copy = func(text string) error { return copyOSC52(out, text) }
+	}
+	// Use the alternate screen, leaving the terminal as it was on exit.
+	fmt.Fprint(out, "\x1b[?1049h")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan []byte)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 256)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				return
+			}
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			keys <- data
+		}
+	}()
+	resize := resized()
+	for !m.Quit() {
+		size, ok := render.TerminalSize(out)
+		if !ok {
+			size = render.Size{Columns: 80, Rows: 24}
+		}
+		if err := draw(out, m.View(size.Columns, size.Rows)); err != nil {
+			return err
+		}
+		select {
+		case data, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, key := range parseKeys(data) {
+				m.Update(key)
+				if m.Quit() {
+					break
+				}
+			}
+		case <-resize:
+		}
+	}
+	return nil
+}
+
+// draw shows a screen, redrawing the whole terminal.
+func draw(w io.Writer, screen *Screen) error {
+	out := bufio.NewWriter(w)
+	out.WriteString("\x1b[?25l\x1b[H")
+	for i, line := range screen.Lines {
+		if i > 0 {
+			out.WriteString("\r\n")
+		}
+		out.WriteString(line)
+		out.WriteString("\x1b[K")
+	}
+	if screen.Col >= 0 {
+		fmt.Fprintf(out, "\x1b[%d;%dH\x1b[?25h", screen.Row+1, screen.Col+1)
+	}
+	return out.Flush()
+}
+
+// openURL opens a URL with the browser of the system.
+func openURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// copyOSC52 asks the terminal to copy a text to the clipboard, which also
+// works over ssh with the terminals supporting it.
+func copyOSC52(w io.Writer, text string) error {
+	_, err := fmt.Fprintf(w, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
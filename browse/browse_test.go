@@ -0,0 +1,118 @@
+package browse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func testModel() (*Model, *database.MemoryRepository, *[]string) {
+	repo := database.NewMemoryRepository()
+	repo.Put(&database.XKCDStrip{ID: 45, Title: "Schrodinger", Date: "2006-01-18", Comment: "I want to believe the cat is alive"})
+	repo.Put(&database.XKCDStrip{ID: 327, Title: "Exploits of a Mom", Date: "2007-10-10",
+		Comment: "Her daughter is named Help I'm trapped in a driver's license factory.", Transcript: "Did you really name your son Robert'); DROP TABLE Students;-- ?"})
+	repo.Put(&database.XKCDStrip{ID: 1171, Title: "Perl Problems", Date: "2013-02-06", Transcript: "I got 99 problems, so I used regular expressions."})
+	var actions []string
+	opts := &Options{
+		Search: &database.SearchOpts{MaxRecords: 10, MinScore: 0.1},
+		Open: func(url string) error {
+			actions = append(actions, "open "+url)
+			return nil
+		},
+		Copy: func(text string) error {
+			actions = append(actions, "copy "+text)
+			return errors.New("no clipboard")
+		},
+	}
+	return NewModel(repo, opts), repo, &actions
+}
+
+// typeKeys sends the keys of a text to the model.
+func typeKeys(m *Model, text string) {
+	for _, key := range parseKeys([]byte(text)) {
+		m.Update(key)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("aé\r\x1b[A\x1b[6~\x1b\x7f\x03\x1b[99x"))
+	assert.Equal(t, []Key{
+		{Rune: 'a'}, {Rune: 'é'}, {Special: KeyEnter}, {Special: KeyUp}, {Special: KeyPageDown},
+		{Special: KeyEscape}, {Special: KeyBackspace}, {Special: KeyCtrlC}, {Special: KeyUnknown},
+	}, keys)
+}
+
+func TestSearchAsYouType(t *testing.T) {
+	m, _, _ := testModel()
+	typeKeys(m, "problems")
+	assert.Len(t, m.results, 1)
+	assert.Equal(t, 1171, m.strip.ID)
+	// Clearing the query clears the results, but not the strip shown.
+	typeKeys(m, "\x15")
+	assert.Len(t, m.results, 0)
+	assert.Equal(t, 1171, m.strip.ID)
+	typeKeys(m, "mom\x7f\x7f\x7f")
+	assert.Equal(t, "", m.query)
+}
+
+func TestListKeys(t *testing.T) {
+	m, repo, actions := testModel()
+	typeKeys(m, "cat\r")
+	assert.Equal(t, modeList, m.mode)
+	assert.Equal(t, 45, m.strip.ID)
+	// Typing doesn't change the query in the list.
+	typeKeys(m, "n")
+	assert.Equal(t, "cat", m.query)
+	assert.Equal(t, 327, m.strip.ID)
+	typeKeys(m, "n")
+	assert.Equal(t, 1171, m.strip.ID)
+	typeKeys(m, "n")
+	assert.Equal(t, 1171, m.strip.ID)
+	assert.Equal(t, "This is the latest strip.", m.status)
+	typeKeys(m, "\x1b[D")
+	assert.Equal(t, 327, m.strip.ID)
+	typeKeys(m, "oy")
+	assert.Equal(t, []string{"open https://xkcd.com/327", "copy https://xkcd.com/327"}, *actions)
+	assert.Equal(t, "Could not copy the URL: no clipboard", m.status)
+	// Tags replace the ones of the strip.
+	typeKeys(m, "tsql injection\r")
+	assert.Equal(t, modeList, m.mode)
+	stored, _ := repo.Get(327)
+	assert.Equal(t, []string{"injection", "sql"}, stored.Tags)
+	typeKeys(m, "t\x15\x1b")
+	stored, _ = repo.Get(327)
+	assert.Equal(t, []string{"injection", "sql"}, stored.Tags)
+	typeKeys(m, "/x")
+	assert.Equal(t, "catx", m.query)
+	typeKeys(m, "\tq")
+	assert.True(t, m.Quit())
+}
+
+func TestView(t *testing.T) {
+	m, _, _ := testModel()
+	screen := m.View(60, 10)
+	assert.Len(t, screen.Lines, 10)
+	assert.Equal(t, 0, screen.Row)
+	assert.Equal(t, len("Search: "), screen.Col)
+	assert.Contains(t, screen.Lines[2], "Type to search.")
+	typeKeys(m, "daughter")
+	screen = m.View(60, 10)
+	assert.Contains(t, screen.Lines[1], " 1 result ")
+	assert.Contains(t, screen.Lines[2], styleReverse+"  327 Exploits of a Mom")
+	assert.Contains(t, screen.Lines[2], "XKCD 327: Exploits of a Mom")
+	assert.Contains(t, strings.Join(screen.Lines, "\n"), "Alt text:")
+	typeKeys(m, "\r")
+	screen = m.View(60, 10)
+	assert.Equal(t, -1, screen.Col)
+	assert.Contains(t, screen.Lines[9], "o open")
+}
+
+func TestWrap(t *testing.T) {
+	assert.Equal(t, []string{"a few", "words", "and a", "longwo", "rd"}, wrap("a few words and a longword", 6))
+	assert.Equal(t, []string{"one", "", "two"}, wrap("one\n\ntwo", 10))
+	assert.Equal(t, "ab…", fit("abcd", 3))
+	assert.Equal(t, "ab  ", fit("ab", 4))
+}
@@ -0,0 +1,99 @@
+package browse
+
+import (
+	"unicode/utf8"
+)
+
+// Key is a key pressed by the user: either a special key, or a character.
+type Key struct {
+	Special int
+	Rune    rune
+}
+
+// The special keys we handle.
+const (
+	KeyRune = iota
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyEscape
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+	KeyCtrlC
+	KeyCtrlU
+	KeyUnknown
+)
+
+// escapes are the sequences sent by the special keys, after the escape.
+var escapes = map[string]int{
+	"[A": KeyUp, "OA": KeyUp,
+	"[B": KeyDown, "OB": KeyDown,
+	"[C": KeyRight, "OC": KeyRight,
+	"[D": KeyLeft, "OD": KeyLeft,
+	"[H": KeyHome, "OH": KeyHome, "[1~": KeyHome, "[7~": KeyHome,
+	"[F": KeyEnd, "OF": KeyEnd, "[4~": KeyEnd, "[8~": KeyEnd,
+	"[5~": KeyPageUp,
+	"[6~": KeyPageDown,
+}
+
+// parseKeys splits what the terminal sent into keys. An escape alone is the
+// escape key; escape sequences we don't know about are KeyUnknown.
+func parseKeys(data []byte) []Key {
+	var keys []Key
+	for len(data) > 0 {
+		var key Key
+		n := 1
+		switch b := data[0]; {
+		case b == 0x1b:
+			key, n = parseEscape(data)
+		case b == '\r' || b == '\n':
+			key.Special = KeyEnter
+		case b == '\t':
+			key.Special = KeyTab
+		case b == 0x7f || b == 0x08:
+			key.Special = KeyBackspace
+		case b == 0x03:
+			key.Special = KeyCtrlC
+		case b == 0x15:
+			key.Special = KeyCtrlU
+		case b == 0x0e:
+			key.Special = KeyDown
+		case b == 0x10:
+			key.Special = KeyUp
+		case b < 0x20:
+			key.Special = KeyUnknown
+		default:
+			var r rune
+			r, n = utf8.DecodeRune(data)
+			key.Rune = r
+		}
+		keys = append(keys, key)
+		data = data[n:]
+	}
+	return keys
+}
+
+// parseEscape parses an escape sequence at the start of data, returning the
+// key and its length.
+func parseEscape(data []byte) (Key, int) {
+	if len(data) == 1 || (data[1] != '[' && data[1] != 'O') {
+		return Key{Special: KeyEscape}, 1
+	}
+	// A sequence ends with a letter or a tilde.
+	for i := 2; i < len(data); i++ {
+		b := data[i]
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || b == '~' {
+			if special, ok := escapes[string(data[1:i+1])]; ok {
+				return Key{Special: special}, i + 1
+			}
+			return Key{Special: KeyUnknown}, i + 1
+		}
+	}
+	return Key{Special: KeyUnknown}, len(data)
+}
@@ -0,0 +1,301 @@
+package browse
+
+import (
+	"strings"
+
+	"github.com/lavagetto/xkcli/database"
+)
+
+// mode decides what the keys typed by the user do.
+type mode int
+
+const (
+	// modeSearch edits the query, searching as the user types.
+	modeSearch mode = iota
+	// modeList moves among the results and acts on the strip shown.
+	modeList
+	// modeTag edits the tags of the strip shown.
+	modeTag
+)
+
+// Options control the browser.
+type Options struct {
+	// Search are the options of the searches run as the user types.
+	Search *database.SearchOpts
+	// Open opens a URL in the browser.
+	Open func(url string) error
+	// Copy copies a text to the clipboard.
+	Copy func(text string) error
+}
+
+// Model is the state of the browser: the query, its results, and the strip
+// shown in the detail pane. It changes only in response to the keys passed
+// to Update, so that it can be tested without a terminal.
+type Model struct {
+	repo  database.Repository
+	opts  *Options
+	mode  mode
+	query string
+	// input is the text typed in the tags prompt.
+	input    string
+	results  []*database.Hit
+	above    int
+	selected int
+	// strip is shown in the detail pane; it's not always the selected
+	// result, as the user can move to the strips before and after it.
+	strip *database.XKCDStrip
+	// scroll is the first line of the detail pane shown.
+	scroll int
+	status string
+	quit   bool
+}
+
+// NewModel returns the initial state of the browser, with an empty query.
+func NewModel(repo database.Repository, opts *Options) *Model {
+	return &Model{repo: repo, opts: opts}
+}
+
+// Quit returns true once the user asked to exit.
+func (m *Model) Quit() bool {
+	return m.quit
+}
+
+// Update changes the state in response to a key.
+func (m *Model) Update(key Key) {
+	m.status = ""
+	if key.Special == KeyCtrlC {
+		m.quit = true
+		return
+	}
+	switch m.mode {
+	case modeSearch:
+		m.updateSearch(key)
+	case modeList:
+		m.updateList(key)
+	case modeTag:
+		m.updateTag(key)
+	}
+}
+
+// updateSearch handles the keys typed in the search box.
+func (m *Model) updateSearch(key Key) {
+	switch key.Special {
+	case KeyRune:
+		m.query += string(key.Rune)
+		m.search()
+	case KeyBackspace:
+		if m.query != "" {
+			runes := []rune(m.query)
+			m.query = string(runes[:len(runes)-1])
+			m.search()
+		}
+	case KeyCtrlU:
+		m.query = ""
+		m.search()
+	case KeyEnter, KeyTab, KeyEscape:
+		m.mode = modeList
+	default:
+		m.move(key)
+	}
+}
+
+// updateList handles the keys typed while moving among the results.
+func (m *Model) updateList(key Key) {
+	switch key.Special {
+	case KeyEscape:
+		m.quit = true
+	case KeyTab:
+		m.mode = modeSearch
+	case KeyRight:
+		m.step(1)
+	case KeyLeft:
+		m.step(-1)
+	case KeyRune:
+		switch key.Rune {
+		case 'q':
+			m.quit = true
+		case '/':
+			m.mode = modeSearch
+		case 'j':
+			m.move(Key{Special: KeyDown})
+		case 'k':
+			m.move(Key{Special: KeyUp})
+		case ' ':
+			m.move(Key{Special: KeyPageDown})
+		case 'b':
+			m.move(Key{Special: KeyPageUp})
+		case 'n':
+			m.step(1)
+		case 'p':
+			m.step(-1)
+		case 'o':
+			m.open()
+		case 'y':
+			m.copyURL()
+		case 't':
+			if m.strip != nil {
+				m.mode = modeTag
+				m.input = strings.Join(m.strip.Tags, " ")
+			}
+		}
+	default:
+		m.move(key)
+	}
+}
+
+// updateTag handles the keys typed in the tags prompt.
+func (m *Model) updateTag(key Key) {
+	switch key.Special {
+	case KeyRune:
+		m.input += string(key.Rune)
+	case KeyBackspace:
+		if m.input != "" {
+			runes := []rune(m.input)
+			m.input = string(runes[:len(runes)-1])
+		}
+	case KeyCtrlU:
+		m.input = ""
+	case KeyEnter:
+		m.saveTags()
+		m.mode = modeList
+	case KeyEscape:
+		m.mode = modeList
+	}
+}
+
+// move handles the keys selecting a result, or scrolling the detail pane.
+func (m *Model) move(key Key) {
+	switch key.Special {
+	case KeyUp:
+		m.choose(m.selected - 1)
+	case KeyDown:
+		m.choose(m.selected + 1)
+	case KeyHome:
+		m.choose(0)
+	case KeyEnd:
+		m.choose(len(m.results) - 1)
+	case KeyPageDown:
+		m.scroll += pageScroll
+	case KeyPageUp:
+		m.scroll -= pageScroll
+		if m.scroll < 0 {
+			m.scroll = 0
+		}
+	}
+}
+
+// pageScroll is how many lines the detail pane scrolls at a time.
+const pageScroll = 10
+
+// search runs the query, keeping the previous results if it's not valid yet,
+// as when a quote was just opened.
+func (m *Model) search() {
+	if strings.TrimSpace(m.query) == "" {
+		m.results, m.above, m.selected = nil, 0, 0
+		return
+	}
+	results, err := m.repo.Search(m.query, m.opts.Search)
+	if err != nil {
+		// Syntax errors span several lines: the first one is enough here.
+		m.status = strings.SplitN(err.Error(), "\n", 2)[0]
+		return
+	}
+	m.results, m.above = results.Hits, results.Above
+	m.choose(0)
+}
+
+// choose selects a result, and shows its strip.
+func (m *Model) choose(i int) {
+	if len(m.results) == 0 {
+		return
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(m.results) {
+		i = len(m.results) - 1
+	}
+	m.selected = i
+	m.show(m.results[i].Strip)
+}
+
+// show displays a strip in the detail pane.
+func (m *Model) show(strip *database.XKCDStrip) {
+	m.strip = strip
+	m.scroll = 0
+}
+
+// step shows the strip with the next (1) or the previous (-1) ID, skipping
+// the ones missing from the database.
+func (m *Model) step(direction int) {
+	latest, err := m.repo.LatestID()
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	id := 0
+	switch {
+	case m.strip != nil:
+		id = m.strip.ID
+	case direction < 0:
+		id = latest + 1
+	}
+	for id += direction; id >= 1 && id <= latest; id += direction {
+		strip, err := m.repo.Get(id)
+		if err != nil {
+			m.status = err.Error()
+			return
+		}
+		if strip != nil {
+			m.show(strip)
+			return
+		}
+	}
+	if direction > 0 {
+		m.status = "This is the latest strip."
+	} else {
+		m.status = "This is the first strip."
+	}
+}
+
+// open opens the strip shown in the browser.
+func (m *Model) open() {
+	if m.strip == nil {
+		return
+	}
+	if err := m.opts.Open(m.strip.URL()); err != nil {
+		m.status = "Could not open the browser: " + err.Error()
+		return
+	}
+	m.status = "Opened " + m.strip.URL()
+}
+
+// copyURL copies the URL of the strip shown to the clipboard.
+func (m *Model) copyURL() {
+	if m.strip == nil {
+		return
+	}
+	if err := m.opts.Copy(m.strip.URL()); err != nil {
+		m.status = "Could not copy the URL: " + err.Error()
+		return
+	}
+	m.status = "Copied " + m.strip.URL()
+}
+
+// saveTags replaces the tags of the strip shown with the ones typed.
+func (m *Model) saveTags() {
+	strip := *m.strip
+	strip.Tags = nil
+	strip.AddTags(strings.Fields(m.input)...)
+	if err := m.repo.Put(&strip); err != nil {
+		m.status = "Could not save the tags: " + err.Error()
+		return
+	}
+	m.strip = &strip
+	for _, hit := range m.results {
+		if hit.Strip.ID == strip.ID {
+			hit.Strip = &strip
+		}
+	}
+	m.status = "Tags saved."
+}
@@ -0,0 +1,20 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package browse
+
+import (
+	"errors"
+	"os"
+)
+
+// makeRaw would put the terminal in raw mode, which we don't support on this system.
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errors.New("the browser is not supported on this system")
+}
+
+// resized returns a channel that never receives anything, as we can't know
+// when the terminal is resized.
+func resized() <-chan os.Signal {
+	return nil
+}
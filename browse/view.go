@@ -0,0 +1,203 @@
+package browse
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lavagetto/xkcli/database"
+)
+
+// The styles of the parts of the screen.
+const (
+	styleReset   = "\x1b[0m"
+	styleBold    = "\x1b[1m"
+	styleDim     = "\x1b[2m"
+	styleReverse = "\x1b[7m"
+)
+
+// The help shown in the status bar, for each mode.
+var help = map[mode]string{
+	modeSearch: "type to search  ↑↓ select  Enter: go to results  Ctrl-U: clear  Ctrl-C: quit",
+	modeList:   "↑↓/jk select  ←→/np prev/next strip  o open  y copy URL  t tag  / search  q quit",
+	modeTag:    "tags separated by spaces  Enter: save  Esc: cancel",
+}
+
+// Screen is what the browser shows: one string per line of the terminal, and
+// where the cursor goes. Col is -1 when the cursor should be hidden.
+type Screen struct {
+	Lines []string
+	Row   int
+	Col   int
+}
+
+// View draws the state of the browser on a terminal of the given size.
+func (m *Model) View(width, height int) *Screen {
+	if width < 20 {
+		width = 20
+	}
+	if height < 5 {
+		height = 5
+	}
+	screen := &Screen{Col: -1}
+	prompt, input := "Search: ", m.query
+	if m.mode == modeTag {
+		prompt, input = fmt.Sprintf("Tags of %d: ", m.strip.ID), m.input
+	}
+	// Keep the end of the input in sight.
+	line := prompt + input
+	if n := utf8.RuneCountInString(line); n >= width {
+		line = string([]rune(line)[n-width+1:])
+	}
+	if m.mode != modeList {
+		screen.Col = utf8.RuneCountInString(line)
+	}
+	screen.Lines = append(screen.Lines, fit(line, width))
+
+	count := ""
+	if strings.TrimSpace(m.query) != "" {
+		count = fmt.Sprintf(" %d results ", m.above)
+		if m.above == 1 {
+			count = " 1 result "
+		}
+	}
+	screen.Lines = append(screen.Lines, styleDim+"──"+count+strings.Repeat("─", width-2-utf8.RuneCountInString(count))+styleReset)
+
+	body := height - 3
+	listWidth := width * 2 / 5
+	detailWidth := width - listWidth - 1
+	list := m.listLines(listWidth, body)
+	detail := m.detailLines(detailWidth, body)
+	for i := 0; i < body; i++ {
+		screen.Lines = append(screen.Lines, list[i]+styleDim+"│"+styleReset+detail[i])
+	}
+
+	status := m.status
+	if status == "" {
+		status = help[m.mode]
+	}
+	screen.Lines = append(screen.Lines, styleReverse+fit(status, width)+styleReset)
+	return screen
+}
+
+// listLines draws the results, scrolling them to keep the selected one in sight.
+func (m *Model) listLines(width, height int) []string {
+	lines := make([]string, height)
+	first := 0
+	if m.selected >= height {
+		first = m.selected - height + 1
+	}
+	for i := range lines {
+		n := first + i
+		if n >= len(m.results) {
+			lines[i] = fit("", width)
+			continue
+		}
+		strip := m.results[n].Strip
+		line := fit(fmt.Sprintf("%5d %s", strip.ID, strip.Title), width)
+		if n == m.selected {
+			line = styleReverse + line + styleReset
+		}
+		lines[i] = line
+	}
+	if len(m.results) == 0 {
+		hint := "Type to search."
+		if strings.TrimSpace(m.query) != "" {
+			hint = "No results."
+		}
+		lines[0] = styleDim + fit(" "+hint, width) + styleReset
+	}
+	return lines
+}
+
+// detailLines draws the strip shown, from the line the user scrolled to.
+func (m *Model) detailLines(width, height int) []string {
+	text := detail(m.strip, width-1)
+	// Don't scroll past the end.
+	if m.scroll > len(text)-height {
+		m.scroll = len(text) - height
+	}
+	if m.scroll < 0 {
+		m.scroll = 0
+	}
+	lines := make([]string, height)
+	for i := range lines {
+		line := ""
+		if m.scroll+i < len(text) {
+			line = text[m.scroll+i]
+		}
+		lines[i] = " " + fit(line, width-1)
+	}
+	if len(text) > 0 && m.scroll == 0 {
+		lines[0] = styleBold + lines[0] + styleReset
+	}
+	return lines
+}
+
+// detail returns the lines describing a strip, wrapped to width.
+func detail(strip *database.XKCDStrip, width int) []string {
+	if strip == nil {
+		return nil
+	}
+	lines := []string{
+		fmt.Sprintf("XKCD %d: %s", strip.ID, strip.Title),
+		strip.Date + "  " + strip.URL(),
+	}
+	var extra []string
+	if len(strip.Tags) > 0 {
+		extra = append(extra, "tags: "+strings.Join(strip.Tags, ", "))
+	}
+	if strip.Favourite {
+		extra = append(extra, "★ favourite")
+	}
+	if len(extra) > 0 {
+		lines = append(lines, strings.Join(extra, "  "))
+	}
+	section := func(title, text string) {
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		lines = append(lines, "", title)
+		lines = append(lines, wrap(text, width)...)
+	}
+	section("Alt text:", strip.Comment)
+	section("Notes:", strings.Join(strip.Notes, "\n"))
+	section("Transcript:", strip.Transcript)
+	return lines
+}
+
+// fit pads or cuts a line to exactly width characters.
+func fit(text string, width int) string {
+	n := utf8.RuneCountInString(text)
+	if n > width {
+		return string([]rune(text)[:width-1]) + "…"
+	}
+	return text + strings.Repeat(" ", width-n)
+}
+
+// wrap breaks a text in lines of at most width characters, between words
+// if possible.
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := []rune{}
+		for _, word := range strings.Fields(paragraph) {
+			w := []rune(word)
+			if len(line) > 0 && len(line)+1+len(w) > width {
+				lines = append(lines, string(line))
+				line = line[:0:0]
+			}
+			if len(line) > 0 {
+				line = append(line, ' ')
+			}
+			line = append(line, w...)
+			// Break words longer than a line.
+			for len(line) > width {
+				lines = append(lines, string(line[:width]))
+				line = line[width:]
+			}
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/lavagetto/xkcli/database"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup <file>",
+	Short: "Save a backup of the local database.",
+	Long: `xkcli backup saves all the strips in the local database, along with your
+data and the metadata of the database, in a single compressed and checksummed
+file, that can be loaded back with xkcli restore.
+
+It is safe to run a backup while other commands are using the database, and
+databases with an outdated schema can be backed up before migrating them.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		requireText(cmd)
+		dbPath := viper.GetString("dbPath")
+		logger := setupLogging(debugLog).Sugar()
+		defer logger.Sync()
+		database.SetLogger(logger)
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		db, err := database.OpenWith(dbPath, &database.OpenOpts{ReadOnly: true, Timeout: timeout})
+		if _, outdated := err.(database.ErrOutdatedSchema); outdated {
+			// Back up the database as it is: restoring it applies the migrations.
+			db, err = bleve.OpenUsing(dbPath, map[string]interface{}{"read_only": true})
+		}
+		if err != nil {
+			fmt.Printf("Could not open the database at %s: %s\n", dbPath, err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		// Write to a temporary file first, so that we never leave a partial backup behind.
+		tmpFile := args[0] + ".tmp"
+		f, err := os.Create(tmpFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		count, err := database.Backup(db, f)
+		if err == nil {
+			err = f.Sync()
+		}
+		f.Close()
+		if err == nil {
+			err = os.Rename(tmpFile, args[0])
+		}
+		if err != nil {
+			os.Remove(tmpFile)
+			fmt.Printf("Could not save the backup: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved %d strips to %s\n", count, args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.Flags().Duration("timeout", 30*time.Second, "How long to wait for other processes to release the database.")
+}
@@ -0,0 +1,111 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lavagetto/xkcli/browse"
+	"github.com/lavagetto/xkcli/database"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// browseCmd represents the browse command
+var browseCmd = &cobra.Command{
+	Use:   "browse",
+	Short: "Browse the strips in a full-screen terminal interface.",
+	Long: `Explore the strips in your database: type a query to search as you type,
+move among the results with the arrows, and read the alt text and the
+transcript of the selected strip on the right.
+
+While moving among the results:
+  ←/→ or p/n   show the previous or next strip, by number
+  o            open the strip in the browser
+  y            copy its URL to the clipboard
+  t            edit its tags
+  / or Tab     go back to the search box
+  q            quit
+
+Everything happens on your database: nothing is downloaded. The database is
+open read-only, and only opened for writing to save the tags.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		requireText(cmd)
+		if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+			fmt.Println("xkcli browse needs a terminal.")
+			os.Exit(1)
+		}
+		// Logs would scribble over the screen.
+		database.SetLogger(zap.NewNop().Sugar())
+		ro, err := openRepository(&database.OpenOpts{ReadOnly: true})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		repo := &browseRepository{Repository: ro}
+		defer repo.Close()
+		opts := *database.DefaultSearchOpts
+		opts.Highlight = ""
+		opts.MinScore = viper.GetFloat64("minScore")
+		opts.MaxRecords, _ = cmd.Flags().GetInt("limit")
+		if opts.MaxRecords < 1 {
+			fmt.Println("--limit must be positive")
+			os.Exit(1)
+		}
+		m := browse.NewModel(repo, &browse.Options{Search: &opts})
+		if err := browse.Run(m, os.Stdin, os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// browseRepository keeps the database open read-only while browsing, so that
+// other commands can use it too, and opens it for writing only to save a strip.
+type browseRepository struct {
+	database.Repository
+}
+
+// Put reopens the database for writing, to store a strip whose tags changed.
+func (r *browseRepository) Put(strip *database.XKCDStrip) error {
+	// The read-only lock would keep us from opening the database for writing.
+	if err := r.Repository.Close(); err != nil {
+		return err
+	}
+	rw, err := openRepository(&database.OpenOpts{Timeout: 2 * time.Second})
+	if err == nil {
+		err = rw.Put(strip)
+		if cerr := rw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	ro, rerr := openRepository(&database.OpenOpts{ReadOnly: true})
+	if rerr != nil {
+		// Keep the closed repository: using it fails instead of panicking.
+		return fmt.Errorf("could not open the database again: %s", rerr)
+	}
+	r.Repository = ro
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(browseCmd)
+	browseCmd.Flags().IntP("limit", "n", 100, "Maximum number of results to show.")
+}
@@ -0,0 +1,273 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion <bash|zsh|fish>",
+	Short: "Generate the completion script for a shell.",
+	Long: `Print the script completing the commands of xkcli in your shell. Besides
+commands and flags, it suggests the numbers and titles of your strips, the
+tags you use, and the words of the titles you search. To load it:
+
+bash:  source <(xkcli completion bash)
+zsh:   source <(xkcli completion zsh)
+fish:  xkcli completion fish | source
+
+Add the line to the configuration of your shell to always have it.`,
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	Args:      cobra.ExactValidArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		requireText(cmd)
+		var err error
+		switch args[0] {
+		case "bash":
+			err = rootCmd.GenBashCompletion(os.Stdout)
+		case "zsh":
+			_, err = fmt.Fprintf(os.Stdout, zshCompletion, rootCmd.Name())
+		case "fish":
+			err = rootCmd.GenFishCompletion(os.Stdout, true)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// zshCompletion asks xkcli for the completions, like the fish script of cobra.
+const zshCompletion = `#compdef %[1]s
+compdef _%[1]s %[1]s
+
+_%[1]s() {
+    local out directive line value
+    local -a lines completions nospace
+    out=$("${words[1]}" __complete "${(@)words[2,CURRENT]}" 2>/dev/null)
+    lines=("${(@f)out}")
+    # The last line is the directive, as in :4.
+    directive=${lines[-1]#:}
+    lines=("${(@)lines[1,-2]}")
+    if (( directive & 1 )); then
+        return 1
+    fi
+    for line in "${lines[@]}"; do
+        [[ -z $line ]] && continue
+        # Colons separate the values from their descriptions.
+        value=${${line%%%%$'\t'*}//:/\\:}
+        if [[ $line == *$'\t'* ]]; then
+            completions+=("$value:${line#*$'\t'}")
+        else
+            completions+=("$value")
+        fi
+    done
+    if (( ${#completions} )); then
+        (( directive & 2 )) && nospace=(-S '')
+        _describe -t %[1]s '%[1]s' completions "${nospace[@]}"
+    elif ! (( directive & 4 )); then
+        _files
+    fi
+}
+
+# Don't run the completion function when the script is sourced.
+if [ "$funcstack[1]" = "_%[1]s" ]; then
+    _%[1]s
+fi
+`
+
+// withStrips calls fn on every strip in the database, for the completions.
+func withStrips(fn func(*database.XKCDStrip)) error {
+	// The configuration was read before parsing the command line being
+	// completed: read it again, in case it has a --config.
+	initConfig()
+	// Logs would end up among the completions.
+	database.SetLogger(zap.NewNop().Sugar())
+	repo, err := openRepository(&database.OpenOpts{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+	return repo.Iterate(func(strip *database.XKCDStrip) error {
+		fn(strip)
+		return nil
+	})
+}
+
+// completeID suggests the numbers of the strips, with their titles, for the
+// first argument of a command.
+func completeID(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var strips []*database.XKCDStrip
+	err := withStrips(func(strip *database.XKCDStrip) {
+		if strings.HasPrefix(strconv.Itoa(strip.ID), toComplete) {
+			strips = append(strips, strip)
+		}
+	})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	sort.Slice(strips, func(i, j int) bool { return strips[i].ID < strips[j].ID })
+	completions := make([]string, len(strips))
+	for i, strip := range strips {
+		completions[i] = fmt.Sprintf("%d\t%s", strip.ID, strip.Title)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// tagCounts returns how many strips have each tag; if id is not zero, only
+// the tags of that strip are counted.
+func tagCounts(id int) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := withStrips(func(strip *database.XKCDStrip) {
+		if id == 0 || strip.ID == id {
+			for _, tag := range strip.Tags {
+				counts[tag]++
+			}
+		}
+	})
+	return counts, err
+}
+
+// tagCompletions suggests the tags starting with toComplete, except the ones in skip.
+func tagCompletions(counts map[string]int, toComplete string, skip []string) []string {
+	var completions []string
+	for tag, count := range counts {
+		skipped := false
+		for _, s := range skip {
+			skipped = skipped || s == tag
+		}
+		if skipped || !strings.HasPrefix(tag, strings.ToLower(toComplete)) {
+			continue
+		}
+		description := fmt.Sprintf("%d strips", count)
+		if count == 1 {
+			description = "1 strip"
+		}
+		completions = append(completions, tag+"\t"+description)
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+// completeTag suggests the tags used in the database, as for --tag.
+func completeTag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	counts, err := tagCounts(0)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return tagCompletions(counts, toComplete, nil), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTagAdd suggests a strip, and then the tags it doesn't have yet.
+func completeTagAdd(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeID(cmd, args, toComplete)
+	}
+	counts, err := tagCounts(0)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	own, err := tagCounts(stripID(args[0]))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	skip := args[1:]
+	for tag := range own {
+		skip = append(skip, tag)
+	}
+	return tagCompletions(counts, toComplete, skip), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTagRm suggests a strip, and then its tags.
+func completeTagRm(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeID(cmd, args, toComplete)
+	}
+	own, err := tagCounts(stripID(args[0]))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return tagCompletions(own, toComplete, args[1:]), cobra.ShellCompDirectiveNoFileComp
+}
+
+// stripID returns the strip number in an argument, or -1 if it's not valid,
+// which matches no strip.
+func stripID(arg string) int {
+	id, err := parseID(arg)
+	if err != nil {
+		return -1
+	}
+	return id
+}
+
+// completeQuery suggests the next word of the titles starting with the words
+// of the query typed so far, ignoring case.
+func completeQuery(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 && toComplete == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	typed := make([]string, len(args))
+	for i, arg := range args {
+		typed[i] = strings.ToLower(arg)
+	}
+	prefix := strings.ToLower(toComplete)
+	titles := make(map[string][]string)
+	err := withStrips(func(strip *database.XKCDStrip) {
+		words := strings.Fields(strings.ToLower(strip.Title))
+		if len(words) <= len(typed) {
+			return
+		}
+		for i, word := range typed {
+			if words[i] != word {
+				return
+			}
+		}
+		if next := words[len(typed)]; strings.HasPrefix(next, prefix) {
+			titles[next] = append(titles[next], strip.Title)
+		}
+	})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	completions := make([]string, 0, len(titles))
+	for word, matching := range titles {
+		description := matching[0]
+		if len(matching) > 1 {
+			description = fmt.Sprintf("%d titles", len(matching))
+		}
+		completions = append(completions, word+"\t"+description)
+	}
+	sort.Strings(completions)
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
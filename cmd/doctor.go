@@ -0,0 +1,212 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/download"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the integrity of the local database of strips.",
+	Long: `xkcli doctor checks that the local database can be opened, that all
+the strips in it are complete and have a valid date, that no strip is missing,
+and that the database uses the current schema.
+
+With --fix, the damaged and missing strips are downloaded again, and the
+database is rebuilt if its schema is outdated.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		requireText(cmd)
+		dbPath := viper.GetString("dbPath")
+		logger := setupLogging(debugLog).Sugar()
+		defer logger.Sync()
+		download.SetLogger(logger)
+		database.SetLogger(logger)
+		analysis, err := analysisConfig()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		db, err := database.OpenWith(dbPath, &database.OpenOpts{ReadOnly: true, Analysis: analysis, Timeout: timeout})
+		if _, outdated := err.(database.ErrOutdatedSchema); outdated {
+			// We can still inspect the documents of an outdated database.
+			db, err = bleve.OpenUsing(dbPath, map[string]interface{}{"read_only": true})
+		}
+		if err == database.ErrLocked {
+			fmt.Printf("The database at %s is in use by another process: wait for it to finish and try again.\n", dbPath)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Could not open the database at %s: %s\n", dbPath, err)
+			fmt.Println("If the database is corrupted, remove it and run `xkcli refresh` to download all strips again.")
+			os.Exit(1)
+		}
+		diagnosis, err := diagnose(db, analysis)
+		db.Close()
+		if err != nil {
+			fmt.Printf("Could not read the database: %s\n", err)
+			os.Exit(1)
+		}
+		printDiagnosis(diagnosis)
+		if diagnosis.Healthy() {
+			return
+		}
+		if fix, _ := cmd.Flags().GetBool("fix"); !fix {
+			fmt.Println("Run `xkcli doctor --fix` to repair the database.")
+			os.Exit(1)
+		}
+
+		// Now open the database for writing; this migrates an outdated schema.
+		opts := &database.OpenOpts{Analysis: analysis, Timeout: timeout}
+		db, err = database.OpenWith(dbPath, opts)
+		if err != nil {
+			fmt.Printf("Could not open the database at %s: %s\n", dbPath, err)
+			os.Exit(1)
+		}
+		// Opening the database rebuilds it if the analysis changed: only
+		// rebuild it if the mapping still differs.
+		drift := false
+		if diagnosis.MappingDrift {
+			drift, err = database.MappingDrift(db, analysis)
+			if err != nil {
+				db.Close()
+				fmt.Printf("Could not read the database: %s\n", err)
+				os.Exit(1)
+			}
+		}
+		if drift {
+			fmt.Println("Rebuilding the database with the current mapping")
+			db, err = database.Rebuild(db, dbPath, opts)
+			if err != nil {
+				fmt.Printf("Could not rebuild the database: %s\n", err)
+				os.Exit(1)
+			}
+		}
+		defer db.Close()
+		for _, docID := range diagnosis.Undecodable {
+			if err := db.Delete(docID); err != nil {
+				logger.Errorw("Could not remove the document", "id", docID, "error", err)
+			}
+		}
+		toDownload := append(diagnosis.Damaged(), diagnosis.Gaps...)
+		var notSaved []int
+		if len(toDownload) > 0 {
+			c, _ := cmd.Flags().GetInt("concurrency")
+			ua, _ := cmd.Flags().GetString("userAgent")
+			mgr := download.Manager{
+				Bus: make(chan struct{}, c),
+				Ua:  ua,
+			}
+			defer mgr.Close()
+			repo := database.NewBleveRepository(db)
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			for _, id := range toDownload {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					w := mgr.Get(i)
+					if w == nil {
+						return
+					}
+					if err := database.SaveDownloaded(repo, database.NewStrip(w)); err != nil {
+						logger.Errorw("Could not save the strip", "id", i, "error", err)
+						mu.Lock()
+						notSaved = append(notSaved, i)
+						mu.Unlock()
+					}
+				}(id)
+			}
+			wg.Wait()
+		}
+		diagnosis, err = diagnose(db, analysis)
+		if err != nil {
+			fmt.Printf("Could not read the database: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("After the repair:")
+		printDiagnosis(diagnosis)
+		if len(notSaved) > 0 {
+			sort.Ints(notSaved)
+			fmt.Printf("Could not save %d of the strips downloaded: %s\n", len(notSaved), database.CompactIDs(notSaved))
+		}
+		if !diagnosis.Healthy() || len(notSaved) > 0 {
+			db.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+// diagnose checks the database, ignoring the strips missing on purpose.
+func diagnose(db bleve.Index, analysis *database.AnalysisConfig) (*database.Diagnosis, error) {
+	diagnosis, err := database.Diagnose(db, analysis)
+	if err != nil {
+		return nil, err
+	}
+	var gaps []int
+	for _, id := range diagnosis.Gaps {
+		if _, skip := idToSkip[id]; !skip {
+			gaps = append(gaps, id)
+		}
+	}
+	diagnosis.Gaps = gaps
+	return diagnosis, nil
+}
+
+func printDiagnosis(d *database.Diagnosis) {
+	fmt.Printf("Documents: %d, schema version: %d\n", d.Documents, d.SchemaVersion)
+	if d.Outdated {
+		fmt.Printf("- The schema is outdated, the current version is %d\n", database.SchemaVersion())
+	}
+	if d.MappingDrift {
+		fmt.Println("- The mapping differs from the current one (did you change the analyzers or synonyms?)")
+	}
+	if len(d.Undecodable) > 0 {
+		fmt.Printf("- %d documents are not valid strips: %v\n", len(d.Undecodable), d.Undecodable)
+	}
+	if len(d.Incomplete) > 0 {
+		fmt.Printf("- %d strips are incomplete: %s\n", len(d.Incomplete), database.CompactIDs(d.Incomplete))
+	}
+	if len(d.InvalidDates) > 0 {
+		fmt.Printf("- %d strips have an invalid date: %s\n", len(d.InvalidDates), database.CompactIDs(d.InvalidDates))
+	}
+	if len(d.Gaps) > 0 {
+		fmt.Printf("- %d strips are missing: %s\n", len(d.Gaps), database.CompactIDs(d.Gaps))
+	}
+	if d.Healthy() {
+		fmt.Println("No problems found.")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().Bool("fix", false, "Repair the problems found.")
+	doctorCmd.Flags().Duration("timeout", 5*time.Second, "How long to wait for other processes to release the database.")
+	doctorCmd.Flags().IntP("concurrency", "c", 1, "Number of parallel threads to launch to download the damaged strips")
+	doctorCmd.Flags().StringP("userAgent", "u", "XKCD-cli Crawler/1.0.0", "The user-agent to use when downloading the contents.")
+}
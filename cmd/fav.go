@@ -0,0 +1,113 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/output"
+	"github.com/spf13/cobra"
+)
+
+// favCmd represents the fav command
+var favCmd = &cobra.Command{
+	Use:               "fav <id>",
+	Short:             "Mark a strip as a favourite.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeID,
+	Run: func(cmd *cobra.Command, args []string) {
+		remove, _ := cmd.Flags().GetBool("remove")
+		updateStrip(args[0], func(strip *database.XKCDStrip) {
+			strip.Favourite = !remove
+		})
+	},
+}
+
+// favsCmd represents the favs command
+var favsCmd = &cobra.Command{
+	Use:   "favs",
+	Short: "List your favourite strips.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format := outputFormat()
+		tmpl := summaryTemplate()
+		logger := setupLogging(debugLog).Sugar()
+		defer logger.Sync()
+		database.SetLogger(logger)
+		repo, err := openRepository(&database.OpenOpts{ReadOnly: true})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer repo.Close()
+		if format != output.Text {
+			err = printFavsDoc(format, repo)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+		found := 0
+		err = repo.Iterate(func(strip *database.XKCDStrip) error {
+			if strip.Favourite {
+				found++
+				printSummary(tmpl, output.NewSummary(strip))
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if found == 0 {
+			fmt.Println("You have no favourite strips yet: add one with `xkcli fav <id>`.")
+		}
+	},
+}
+
+// printFavsDoc prints the favourite strips in one of the machine formats.
+func printFavsDoc(format output.Format, repo database.Repository) error {
+	if format.Tabular() {
+		return writeRecords(format, output.StripColumns, func(w *output.Writer) error {
+			return repo.Iterate(func(strip *database.XKCDStrip) error {
+				if !strip.Favourite {
+					return nil
+				}
+				return w.Write(output.NewStrip(strip))
+			})
+		})
+	}
+	favs := []*output.Strip{}
+	err := repo.Iterate(func(strip *database.XKCDStrip) error {
+		if strip.Favourite {
+			favs = append(favs, output.NewStrip(strip))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return output.Encode(os.Stdout, format, favs)
+}
+
+func init() {
+	rootCmd.AddCommand(favCmd)
+	rootCmd.AddCommand(favsCmd)
+	favCmd.Flags().BoolP("remove", "r", false, "Remove the strip from the favourites.")
+}
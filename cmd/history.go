@@ -0,0 +1,200 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/history"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history [number]",
+	Short: "List the past searches, or run one again.",
+	Long: `Without arguments, list the most recent searches, numbered from the oldest.
+Given the number of a search, run it again with the same flags:
+
+xkcli history 42
+
+Set "history: false" in the configuration to stop recording the searches.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		clear, _ := cmd.Flags().GetBool("clear")
+		// A search run again is printed in the format chosen, like by `xkcli search`.
+		if len(args) == 0 || clear {
+			requireText(cmd)
+		}
+		store, err := historyStore()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if clear {
+			if err := store.Clear(); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		entries, err := store.Entries()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if len(args) == 0 {
+			limit, _ := cmd.Flags().GetInt("limit")
+			first := 0
+			if limit > 0 && len(entries) > limit {
+				first = len(entries) - limit
+			}
+			for i := first; i < len(entries); i++ {
+				fmt.Println(formatEntry(i+1, entries[i]))
+			}
+			return
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > len(entries) {
+			fmt.Printf("No search number %s in the history.\n", args[0])
+			os.Exit(1)
+		}
+		rerun(entries[n-1])
+	},
+}
+
+var historyStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the most searched terms and the most found strips.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		requireText(cmd)
+		store, err := historyStore()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		entries, err := store.Entries()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		limit, _ := cmd.Flags().GetInt("limit")
+		stats := history.ComputeStats(entries, limit)
+		fmt.Printf("Searches: %d\n", stats.Searches)
+		fmt.Println("Most searched terms:")
+		for _, term := range stats.Terms {
+			fmt.Printf("\t%s: %d\n", term.Key, term.Count)
+		}
+		fmt.Println("Most found strips:")
+		for _, strip := range stats.Strips {
+			fmt.Printf("\t%s: %d\n", strip.Key, strip.Count)
+		}
+	},
+}
+
+// historyStore returns the store of the search history.
+func historyStore() (*history.Store, error) {
+	historyFile, err := homedir.Expand(viper.GetString("historyFile"))
+	if err != nil {
+		return nil, err
+	}
+	return history.NewStore(historyFile), nil
+}
+
+// recordSearch adds a search to the history, unless the user disabled it.
+// Failing to do so is not a reason to fail the search.
+func recordSearch(cmd *cobra.Command, queryStr string, results *database.SearchResults, lucky bool, logger *zap.SugaredLogger) {
+	if !viper.GetBool("history") {
+		return
+	}
+	entry := history.Entry{
+		Time:  time.Now().UTC(),
+		Query: queryStr,
+		Lucky: lucky,
+	}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		// Global flags like --config are not part of the search.
+		if cmd.InheritedFlags().Lookup(f.Name) != nil {
+			return
+		}
+		if entry.Flags == nil {
+			entry.Flags = make(map[string]string)
+		}
+		value := f.Value.String()
+		if f.Value.Type() == "stringToString" {
+			// Make the value valid for Set again.
+			value = strings.Trim(value, "[]")
+		}
+		entry.Flags[f.Name] = value
+	})
+	if len(results.Hits) > 0 {
+		entry.TopResult = results.Hits[0].Strip.ID
+	}
+	store, err := historyStore()
+	if err == nil {
+		err = store.Add(&entry)
+	}
+	if err != nil {
+		logger.Warnw("Could not save the search in the history", "error", err)
+	}
+}
+
+// formatEntry shows an entry of the history as a line.
+func formatEntry(n int, e *history.Entry) string {
+	line := fmt.Sprintf("%5d  %s  %q", n, e.Time.Local().Format("2006-01-02 15:04"), e.Query)
+	names := make([]string, 0, len(e.Flags))
+	for name := range e.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		line += fmt.Sprintf(" --%s=%s", name, e.Flags[name])
+	}
+	if e.TopResult != 0 {
+		line += fmt.Sprintf(" → %d", e.TopResult)
+	}
+	return line
+}
+
+// rerun runs a search from the history again.
+func rerun(e *history.Entry) {
+	for name, value := range e.Flags {
+		if err := searchCmd.Flags().Set(name, value); err != nil {
+			fmt.Printf("Can't run the search again: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	searchCmd.Run(searchCmd, []string{e.Query})
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyStatsCmd)
+	historyCmd.Flags().IntP("limit", "n", 20, "Number of searches to list, 0 for all of them.")
+	historyCmd.Flags().Bool("clear", false, "Remove all the history.")
+	historyStatsCmd.Flags().IntP("limit", "n", 10, "Number of terms and strips to show.")
+}
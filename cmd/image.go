@@ -0,0 +1,125 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"image"
+	// Register the formats of the images of the strips.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/download"
+	"github.com/lavagetto/xkcli/output"
+	"github.com/lavagetto/xkcli/render"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// wantImage returns true if the image of a strip should be shown: only when
+// printing text to a terminal, and if the user didn't disable it.
+func wantImage(cmd *cobra.Command) bool {
+	show := viper.GetBool("images")
+	if cmd.Flags().Changed("image") {
+		show, _ = cmd.Flags().GetBool("image")
+	}
+	return show && isTerminal(os.Stdout) && outputFormat() == output.Text
+}
+
+// imageOptions returns how to show images in the terminal.
+func imageOptions() (*render.Options, error) {
+	protocol, err := render.ParseProtocol(viper.GetString("imageProtocol"))
+	if err != nil {
+		return nil, err
+	}
+	if protocol == render.Auto {
+		protocol = render.Detect(os.Getenv)
+	}
+	size, _ := render.TerminalSize(os.Stdout)
+	colourTerm := os.Getenv("COLORTERM")
+	return &render.Options{
+		Protocol:   protocol,
+		Size:       size,
+		TrueColour: colourTerm == "truecolor" || colourTerm == "24bit",
+	}, nil
+}
+
+// stripImage returns the image of a strip, from the local mirror if it's
+// there, or downloading it. Downloaded images are added to the mirror.
+func stripImage(strip *database.XKCDStrip, ua string, logger *zap.SugaredLogger) (image.Image, error) {
+	dir, err := homedir.Expand(viper.GetString("imageDir"))
+	if err != nil {
+		return nil, err
+	}
+	local := ""
+	if name := path.Base(strip.Img); dir != "" && name != "." && name != "/" {
+		local = filepath.Join(dir, name)
+	}
+	var data []byte
+	if local != "" {
+		data, err = ioutil.ReadFile(local)
+	}
+	if local == "" || err != nil {
+		mgr := download.Manager{
+			Bus: make(chan struct{}, 1),
+			Ua:  ua,
+		}
+		defer mgr.Close()
+		data, err = mgr.GetImage(strip.Img)
+		if err != nil {
+			return nil, err
+		}
+		if local != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				logger.Warnw("Could not create the directory of the images", "dir", dir, "error", err)
+			} else if err := ioutil.WriteFile(local, data, 0644); err != nil {
+				logger.Warnw("Could not save the image", "file", local, "error", err)
+			}
+		}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// printImage shows the image of a strip in the terminal. Not being able to
+// is no reason to fail, so errors are just logged.
+func printImage(strip *database.XKCDStrip, ua string, logger *zap.SugaredLogger) {
+	opts, err := imageOptions()
+	if err != nil {
+		logger.Warnw("Not showing the image", "error", err)
+		return
+	}
+	if opts.Protocol == render.None || strip.Img == "" {
+		return
+	}
+	img, err := stripImage(strip, ua, logger)
+	if err != nil {
+		logger.Warnw("Could not get the image of the strip", "id", strip.ID, "img", strip.Img, "error", err)
+		return
+	}
+	logger.Debugw("Showing the image of the strip", "id", strip.ID, "protocol", opts.Protocol, "size", opts.Size)
+	if err := render.Render(os.Stdout, img, opts); err != nil {
+		logger.Warnw("Could not show the image of the strip", "id", strip.ID, "error", err)
+	}
+}
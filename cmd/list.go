@@ -0,0 +1,103 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/output"
+	"github.com/spf13/cobra"
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the strips in the database.",
+	Long: `List all the strips in the database, or some of them:
+
+xkcli list --sort -date --from 1000 --to 1100
+xkcli list --missing-transcript
+
+Use --output to get the list in one of the machine-readable formats.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := &database.ListOpts{}
+		opts.Sort, _ = cmd.Flags().GetString("sort")
+		opts.From, _ = cmd.Flags().GetInt("from")
+		opts.To, _ = cmd.Flags().GetInt("to")
+		opts.MissingTranscript, _ = cmd.Flags().GetBool("missing-transcript")
+		format := outputFormat()
+		logger := setupLogging(debugLog).Sugar()
+		defer logger.Sync()
+		database.SetLogger(logger)
+		repo, err := openRepository(&database.OpenOpts{ReadOnly: true})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer repo.Close()
+		lister, ok := repo.(database.Lister)
+		if !ok {
+			fmt.Println("This database can't list its strips.")
+			os.Exit(1)
+		}
+		if format != output.Text {
+			err = writeRecords(format, output.StripColumns, func(w *output.Writer) error {
+				return lister.List(opts, func(strip *database.XKCDStrip) error {
+					return w.Write(output.NewStrip(strip))
+				})
+			})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+		count := 0
+		err = lister.List(opts, func(strip *database.XKCDStrip) error {
+			// The header is printed only once we know the options are valid.
+			if count == 0 {
+				printListHeader()
+			}
+			count++
+			fmt.Printf("%5d  %-10s  %s\n", strip.ID, strip.Date, strip.Title)
+			return nil
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if count == 0 {
+			printListHeader()
+		}
+		fmt.Printf("%d strips\n", count)
+	},
+}
+
+// printListHeader prints the header of the table of strips.
+func printListHeader() {
+	fmt.Printf("%5s  %-10s  %s\n", "ID", "Date", "Title")
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().String("sort", "id", "Order of the strips: id, date or title, optionally preceded by - to reverse it.")
+	listCmd.Flags().Int("from", 0, "Only list the strips with at least this ID.")
+	listCmd.Flags().Int("to", 0, "Only list the strips with at most this ID.")
+	listCmd.Flags().Bool("missing-transcript", false, "Only list the strips without a transcript.")
+}
@@ -0,0 +1,56 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"strings"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/spf13/cobra"
+)
+
+// noteCmd represents the note command
+var noteCmd = &cobra.Command{
+	Use:   "note <id> [text]",
+	Short: "Add a personal note to a strip.",
+	Long: `Attach a note to a strip, to remember why or when it's useful:
+
+xkcli note 1171 "perfect for regex discussions"
+
+Notes are searched together with the strip, so the search above will find
+strip 1171 with "xkcli search regex discussions". Without a text, the notes
+of the strip are shown.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeID,
+	Run: func(cmd *cobra.Command, args []string) {
+		clear, _ := cmd.Flags().GetBool("clear")
+		if len(args) == 1 && !clear {
+			updateStrip(args[0], nil)
+			return
+		}
+		updateStrip(args[0], func(strip *database.XKCDStrip) {
+			if clear {
+				strip.Notes = nil
+			}
+			strip.AddNote(strings.Join(args[1:], " "))
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(noteCmd)
+	noteCmd.Flags().Bool("clear", false, "Remove the existing notes of the strip first.")
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// randomCmd represents the random command
+var randomCmd = &cobra.Command{
+	Use:   "random [query]...",
+	Short: "Show a random strip.",
+	Long: `Pick a strip at random, optionally among the ones matching a query,
+published in some years or with a tag:
+
+xkcli random
+xkcli random --year 2008-2010 --tag security
+xkcli random physics`,
+	ValidArgsFunction: completeQuery,
+	Run: func(cmd *cobra.Command, args []string) {
+		format := outputFormat()
+		tmpl := summaryTemplate()
+		opts := &database.RandomOpts{Query: strings.Join(args, " ")}
+		opts.Tag, _ = cmd.Flags().GetString("tag")
+		opts.Search = *database.DefaultSearchOpts
+		opts.Search.MinScore = viper.GetFloat64("minScore")
+		if years, _ := cmd.Flags().GetString("year"); years != "" {
+			var err error
+			opts.Search.Since, opts.Search.Until, err = parseYears(years)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		seed := time.Now().UnixNano()
+		if cmd.Flags().Changed("seed") {
+			seed, _ = cmd.Flags().GetInt64("seed")
+		}
+		logger := setupLogging(debugLog).Sugar()
+		defer logger.Sync()
+		database.SetLogger(logger)
+		logger.Debugw("Picking a random strip", "seed", seed)
+		repo, err := openRepository(&database.OpenOpts{ReadOnly: true})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer repo.Close()
+		strip, err := database.Random(repo, opts, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if strip == nil {
+			fmt.Println("No strip matches your filters.")
+			os.Exit(1)
+		}
+		if format != output.Text {
+			printStripDoc(format, strip)
+			return
+		}
+		if lucky, _ := cmd.Flags().GetBool("lucky"); lucky {
+			fmt.Println(strip.URL())
+			return
+		}
+		printSummary(tmpl, output.NewSummary(strip))
+	},
+}
+
+// parseYears interprets a year (2010) or a range of years (2008-2010).
+func parseYears(value string) (time.Time, time.Time, error) {
+	parts := strings.SplitN(value, "-", 2)
+	first, err := strconv.Atoi(parts[0])
+	last := first
+	if err == nil && len(parts) == 2 {
+		last, err = strconv.Atoi(parts[1])
+	}
+	if err != nil || last < first {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid years %q: use a year like 2010, or a range like 2008-2010", value)
+	}
+	return time.Date(first, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(last+1, 1, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+func init() {
+	rootCmd.AddCommand(randomCmd)
+	randomCmd.Flags().String("year", "", "Only pick strips published in this year, or range of years like 2008-2010.")
+	randomCmd.Flags().String("tag", "", "Only pick strips with this tag.")
+	randomCmd.RegisterFlagCompletionFunc("tag", completeTag)
+	randomCmd.Flags().Int64("seed", 0, "Seed of the random choice, to always pick the same strip.")
+	randomCmd.Flags().BoolP("lucky", "l", false, "Return just the url of the strip (for IRC usage).")
+}
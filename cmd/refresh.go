@@ -16,10 +16,14 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"sort"
 	"sync"
 
 	"github.com/lavagetto/xkcli/database"
 	"github.com/lavagetto/xkcli/download"
+	"github.com/lavagetto/xkcli/output"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -33,9 +37,13 @@ var refreshCmd = &cobra.Command{
 	Use:   "refresh",
 	Short: "Download the info about the missing strips.",
 	Long: `xkcli refresh will refresh the local database of strips, 
-fetching all the  relative metadata.`,
+fetching all the  relative metadata.
+
+It exits with status 1 if some of the strips could not be downloaded, so
+that scripts can retry later.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		dbPath := viper.GetString("dbPath")
+		format := outputFormat()
 		logger := setupLogging(debugLog).Sugar()
 		defer logger.Sync()
 		download.SetLogger(logger)
@@ -43,11 +51,17 @@ fetching all the  relative metadata.`,
 		// the waitgroup is used to wait for all the goroutines to be done.
 		var wg sync.WaitGroup
 		logger.Debug("Showing logs at debug level")
-		db, err := database.Open(dbPath)
+		repo, err := openRepository(&database.OpenOpts{
+			Progress: func(done, total int) {
+				if done%100 == 0 || done == total {
+					logger.Infof("Migrating the database: %d/%d strips", done, total)
+				}
+			},
+		})
 		if err != nil {
 			logger.Fatalw("Unable to open the database", "path", dbPath, "error", err)
 		}
-		defer db.Close()
+		defer repo.Close()
 		// Setup the download manager
 		c, _ := cmd.Flags().GetInt("concurrency")
 		bus := make(chan struct{}, c)
@@ -63,7 +77,10 @@ fetching all the  relative metadata.`,
 		// Determine which strips to download. We will start from the highest-id
 		// strip we have, and add maxRecords new strips.
 		logger.Debug("Fetching the most recent ID in the database.")
-		lastInDb := database.GetLatestID(db)
+		lastInDb, err := repo.LatestID()
+		if err != nil {
+			logger.Errorw("Could not find the latest ID in the database", "error", err)
+		}
 		logger.Debugf("Maximum stored ID found: %d", lastInDb)
 		logger.Debug("Fetching the latest ID")
 		latest := mgr.GetLatestID()
@@ -72,8 +89,18 @@ fetching all the  relative metadata.`,
 		}
 		logger.Debugf("Max id is %d", latest)
 		toDownload := make([]int, 0)
-		// Now search for missing strips in the database
-		existingIDs := database.GetAllIDs(db, latest)
+		// Now search for missing strips in the database, unless we're
+		// downloading everything again.
+		existingIDs := make(map[int]bool)
+		if force, _ := cmd.Flags().GetBool("force"); !force {
+			err = repo.Iterate(func(strip *database.XKCDStrip) error {
+				existingIDs[strip.ID] = true
+				return nil
+			})
+			if err != nil {
+				logger.Fatalw("Could not read the strips in the database", "error", err)
+			}
+		}
 		for i := 1; i <= latest; i++ {
 			if _, ok := existingIDs[i]; ok {
 				continue
@@ -87,32 +114,80 @@ fetching all the  relative metadata.`,
 				break
 			}
 		}
+		report := output.Refresh{Latest: latest, Downloaded: []int{}, Failed: []int{}}
 		if len(toDownload) == 0 {
 			logger.Info("Nothing to download")
+			printRefresh(format, &report)
 			return
 		}
 		logger.Info("Downloading strips")
 
 		// download and index data
+		var mu sync.Mutex
 		for _, id := range toDownload {
 			logger.Debugf("Scheduling download of id %d", id)
 			wg.Add(1)
 			go func(i int, wg *sync.WaitGroup) {
 				defer wg.Done()
+				var err error
 				w := mgr.Get(i)
 				if w != nil {
 					doc := database.NewStrip(w)
-					err := doc.Index(db)
+					err = database.SaveDownloaded(repo, doc)
 					if err == nil {
 						logger.Infof("Indexed strip %s", doc.Summary())
 					}
 				}
+				mu.Lock()
+				defer mu.Unlock()
+				if w == nil || err != nil {
+					report.Failed = append(report.Failed, i)
+				} else {
+					report.Downloaded = append(report.Downloaded, i)
+				}
 			}(id, &wg)
 		}
 		wg.Wait()
+		sort.Ints(report.Downloaded)
+		sort.Ints(report.Failed)
+		printRefresh(format, &report)
+		if len(report.Failed) > 0 {
+			// os.Exit skips the deferred calls.
+			mgr.Close()
+			repo.Close()
+			logger.Sync()
+			os.Exit(1)
+		}
 	},
 }
 
+// printRefresh prints the report of a refresh.
+func printRefresh(format output.Format, report *output.Refresh) {
+	var err error
+	switch {
+	case format == output.Text:
+		fmt.Printf("Downloaded %d strips, the latest is %d.\n", len(report.Downloaded), report.Latest)
+		if len(report.Failed) > 0 {
+			fmt.Printf("Could not download %d strips: %s\n", len(report.Failed), database.CompactIDs(report.Failed))
+		}
+	case format.Tabular():
+		err = writeRecords(format, output.RefreshColumns, func(w *output.Writer) error {
+			for _, strip := range report.Strips() {
+				if err := w.Write(strip); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	default:
+		err = output.Encode(os.Stdout, format, report)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(refreshCmd)
 
@@ -128,4 +203,5 @@ func init() {
 	refreshCmd.Flags().IntP("concurrency", "c", 1, "Number of parallel threads to launch to download missing strips")
 	refreshCmd.Flags().StringP("userAgent", "u", "XKCD-cli Crawler/1.0.0", "The user-agent to use when downloading the contents.")
 	refreshCmd.Flags().IntP("maxRecords", "m", 0, "Maximum number of records to retreive. By default unbounded.")
+	refreshCmd.Flags().BoolP("force", "f", false, "Download again the strips already in the database, keeping your tags.")
 }
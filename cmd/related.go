@@ -0,0 +1,113 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/output"
+	"github.com/spf13/cobra"
+)
+
+// relatedCmd represents the related command
+var relatedCmd = &cobra.Command{
+	Use:   "related <id>",
+	Short: "Find the strips similar to a given one.",
+	Long: `Find the strips most similar to a given one, based on the words that
+best characterize its title, alt text and transcript:
+
+xkcli related 327`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeID,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := parseID(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		format := outputFormat()
+		tmpl := summaryTemplate()
+		logger := setupLogging(debugLog).Sugar()
+		defer logger.Sync()
+		database.SetLogger(logger)
+		repo, err := openRepository(&database.OpenOpts{ReadOnly: true})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer repo.Close()
+		relater, ok := repo.(database.Relater)
+		if !ok {
+			fmt.Println("This database doesn't support searching related strips.")
+			os.Exit(1)
+		}
+		opts := *database.DefaultSearchOpts
+		opts.Highlight = ""
+		opts.MaxRecords, _ = cmd.Flags().GetInt("limit")
+		if opts.MaxRecords < 1 {
+			fmt.Println("--limit must be positive")
+			os.Exit(1)
+		}
+		results, err := relater.Related(id, &opts)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if format != output.Text {
+			printRelated(format, output.NewRelated(id, results))
+			if len(results.Hits) == 0 {
+				os.Exit(1)
+			}
+			return
+		}
+		if len(results.Hits) == 0 {
+			fmt.Printf("No strips similar to %d found.\n", id)
+			os.Exit(1)
+		}
+		fmt.Printf("Strips related to %d:\n", id)
+		for pos, hit := range results.Hits {
+			printSummary(tmpl, output.NewHitSummary(pos+1, hit))
+		}
+	},
+}
+
+// printRelated prints the related strips in one of the machine formats.
+func printRelated(format output.Format, related *output.Related) {
+	var err error
+	if format.Tabular() {
+		err = writeRecords(format, output.ResultColumns, func(w *output.Writer) error {
+			for _, result := range related.Results {
+				if err := w.Write(result); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	} else {
+		err = output.Encode(os.Stdout, format, related)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(relatedCmd)
+	relatedCmd.Flags().IntP("limit", "n", 10, "Number of related strips to show.")
+}
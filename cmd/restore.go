@@ -0,0 +1,75 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore the local database from a backup.",
+	Long: `xkcli restore replaces the local database with the contents of a backup
+created with xkcli backup.
+
+The backup is verified and loaded into a new database, which then takes the
+place of the current one: if the backup is damaged or can't be loaded, the
+current database is left untouched. The current database is only replaced
+once the other commands using it are done; on Linux it's replaced in a single
+step, elsewhere commands started in that moment might not find it.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		requireText(cmd)
+		dbPath := viper.GetString("dbPath")
+		logger := setupLogging(debugLog).Sugar()
+		defer logger.Sync()
+		database.SetLogger(logger)
+		analysis, err := analysisConfig()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		count, err := database.Restore(f, dbPath, &database.OpenOpts{Analysis: analysis, Timeout: timeout})
+		if err == database.ErrLocked {
+			fmt.Printf("The database at %s is in use by another process: wait for it to finish and try again.\n", dbPath)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Could not restore the backup: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Restored %d strips to %s\n", count, dbPath)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().Duration("timeout", 30*time.Second, "How long to wait for other processes to release the database.")
+}
@@ -19,7 +19,12 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/output"
+	"github.com/lavagetto/xkcli/render"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -28,6 +33,8 @@ import (
 
 var cfgFile string
 var debugLog bool
+var outputName string
+var templateText string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -69,10 +76,108 @@ func setupLogging(isDebug bool) *zap.Logger {
 	return log
 }
 
+// isTerminal returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// analysisConfig builds the text analysis configuration from the user configuration.
+func analysisConfig() (*database.AnalysisConfig, error) {
+	analysis := &database.AnalysisConfig{Analyzers: viper.GetStringMapString("analyzers")}
+	synonymsFile := viper.GetString("synonymsFile")
+	if synonymsFile == "" {
+		return analysis, nil
+	}
+	synonymsFile, err := homedir.Expand(synonymsFile)
+	if err != nil {
+		return nil, err
+	}
+	analysis.Synonyms, err = database.LoadSynonyms(synonymsFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the synonyms file %s: %s", synonymsFile, err)
+	}
+	return analysis, nil
+}
+
+// parseID parses the ID of a strip given on the command line.
+func parseID(arg string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid strip number %q", arg)
+	}
+	return id, nil
+}
+
+// openRepository opens the database configured by the user.
+func openRepository(opts *database.OpenOpts) (database.Repository, error) {
+	analysis, err := analysisConfig()
+	if err != nil {
+		return nil, err
+	}
+	opts.Analysis = analysis
+	repo, err := database.OpenRepository(viper.GetString("dbPath"), opts)
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.xkcli.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&debugLog, "debug", false, "Show more logs.")
+	rootCmd.PersistentFlags().StringVarP(&outputName, "output", "o", string(output.Text), "Output format: text, json, jsonl, yaml, csv or tsv.")
+	rootCmd.PersistentFlags().StringVar(&templateText, "template", "", "Template of the summaries of the strips: one of "+strings.Join(output.PresetNames(), ", ")+", or a Go template like '{{.ID}} {{.Title}}'.")
+}
+
+// outputFormat returns the output format chosen by the user.
+func outputFormat() output.Format {
+	format, err := output.ParseFormat(outputName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return format
+}
+
+// summaryTemplate returns the template of the summaries chosen by the user,
+// on the command line or in the configuration.
+func summaryTemplate() *output.Template {
+	text := templateText
+	if text == "" {
+		text = viper.GetString("summaryTemplate")
+	}
+	tmpl, err := output.ParseTemplate(text, isTerminal(os.Stdout))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return tmpl
+}
+
+// printSummary prints the summary of a strip with a template.
+func printSummary(tmpl *output.Template, summary *output.Summary) {
+	if err := tmpl.Execute(os.Stdout, summary); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// writeRecords prints a list of records in one of the machine formats;
+// write is called to print the records.
+func writeRecords(format output.Format, columns []string, write func(*output.Writer) error) error {
+	w, err := output.NewWriter(os.Stdout, format, columns)
+	if err != nil {
+		return err
+	}
+	if err := write(w); err != nil {
+		return err
+	}
+	return w.Close()
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -85,6 +190,15 @@ func initConfig() {
 	}
 	viper.SetDefault("dbPath", path.Join(home, ".xkcli.db"))
 	viper.SetDefault("minScore", float64(0.5))
+	viper.SetDefault("fuzzyMinScore", float64(0.1))
+	viper.SetDefault("fuzzyFallback", database.MaxFuzziness)
+	viper.SetDefault("history", true)
+	viper.SetDefault("historyFile", path.Join(home, ".xkcli_history"))
+	viper.SetDefault("summaryTemplate", output.DefaultTemplate)
+	viper.SetDefault("images", true)
+	viper.SetDefault("imageProtocol", string(render.Auto))
+	viper.SetDefault("imageDir", path.Join(home, ".xkcli_images"))
+	viper.SetDefault("listenAddress", "localhost:8080")
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -99,3 +213,28 @@ func initConfig() {
 	// If a config file is found, read it in.
 	viper.ReadInConfig()
 }
+
+// printStripDoc prints a strip in one of the machine formats.
+func printStripDoc(format output.Format, strip *database.XKCDStrip) {
+	var err error
+	if format.Tabular() {
+		err = writeRecords(format, output.StripColumns, func(w *output.Writer) error {
+			return w.Write(output.NewStrip(strip))
+		})
+	} else {
+		err = output.Encode(os.Stdout, format, output.NewStrip(strip))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// requireText fails if the user asked for a machine format, for the commands
+// that only print text.
+func requireText(cmd *cobra.Command) {
+	if format := outputFormat(); format != output.Text {
+		fmt.Printf("`%s` has no %s output.\n", cmd.CommandPath(), format)
+		os.Exit(1)
+	}
+}
@@ -18,66 +18,306 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/blevesearch/bleve/search"
 	"github.com/spf13/viper"
 
 	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/output"
 	"github.com/spf13/cobra"
 )
 
 // searchCmd represents the search command
 var searchCmd = &cobra.Command{
-	Use:   "search",
+	Use:   "search <query>...",
 	Short: "Search XKCD strips",
 	Long: `Search the xkcd database for a string:
 
-Don't forget to quote your query on the shell.`,
+xkcli search bobby tables
+
+The query can also contain:
+  - "quoted phrases", to match words in that order;
+  - field:word or field:"a phrase", to search only in one field among
+    title, alt, transcript, notes, tags and title_exact;
+  - +word, to only show the strips containing a word;
+  - -word, to exclude the strips containing a word;
+  - #327, to match the strip with that number.
+Remember to quote phrases on the shell, as in: xkcli search '"star trek"'
+
+With --raw, the query is passed to bleve unchanged, and uses its query
+string syntax: https://blevesearch.com/docs/Query-String-Query/`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeQuery,
 	Run: func(cmd *cobra.Command, args []string) {
-		dbPath := viper.GetString("dbPath")
 		minScore := viper.GetFloat64("minScore")
-		doFeelLucky, err := cmd.Flags().GetBool("lucky")
+		doFeelLucky, _ := cmd.Flags().GetBool("lucky")
+		queryStr := strings.Join(args, " ")
+		format := outputFormat()
+		tmpl := summaryTemplate()
+		showFacets, _ := cmd.Flags().GetBool("facets")
+		if showFacets && format.Tabular() {
+			fmt.Printf("Facets can't be shown in the %s format.\n", format)
+			os.Exit(1)
+		}
+		logger := setupLogging(debugLog).Sugar()
+		defer logger.Sync()
+		database.SetLogger(logger)
+		repo, err := openRepository(&database.OpenOpts{ReadOnly: true})
 		if err != nil {
-			panic(err)
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		db, err := database.Open(dbPath)
+		defer repo.Close()
+		opts, err := searchOpts(cmd)
 		if err != nil {
-			panic(err)
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		defer db.Close()
-		searchResult, err := database.SearchStr(db, args[0], nil)
+		opts.MinScore = minScore
+		if opts.Fuzziness > 0 {
+			opts.MinScore = viper.GetFloat64("fuzzyMinScore")
+		}
+		results, err := repo.Search(queryStr, opts)
 		if err != nil {
-			panic(err)
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		// If nothing matches exactly, see if the user made a typo.
+		fallback := viper.GetInt("fuzzyFallback")
+		if results.MaxScore < opts.MinScore && opts.Fuzziness == 0 && fallback > 0 {
+			logger.Debugw("No results above the threshold, trying a fuzzy search", "fuzziness", fallback)
+			opts.Fuzziness = fallback
+			opts.MinScore = viper.GetFloat64("fuzzyMinScore")
+			results, err = repo.Search(queryStr, opts)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if results.MaxScore >= opts.MinScore && !doFeelLucky {
+				// Don't mix the notice with the results in the machine formats.
+				notice := os.Stdout
+				if format != output.Text {
+					notice = os.Stderr
+				}
+				fmt.Fprintln(notice, "No exact matches found, showing approximate matches.")
+			}
 		}
-		if searchResult.MaxScore < minScore {
+		recordSearch(cmd, queryStr, results, doFeelLucky, logger)
+		var facets *database.Facets
+		if faceter, ok := repo.(database.Faceter); ok && showFacets {
+			facets, err = faceter.Facets(queryStr, opts)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		if format != output.Text {
+			printSearch(format, queryStr, opts, results, facets)
+			if len(results.Hits) == 0 {
+				os.Exit(1)
+			}
+			return
+		}
+		// With a minScore of 0, a query matching nothing is not below it.
+		if results.MaxScore < opts.MinScore || results.Total == 0 {
 			fmt.Println("No result matching the query abouve minimum score")
 			os.Exit(1)
 		}
-		if doFeelLucky {
-			strip := database.NewStripFromDb(searchResult.Hits[0])
-			fmt.Println(strip.URL())
+		if doFeelLucky && len(results.Hits) > 0 {
+			if wantImage(cmd) {
+				ua, _ := cmd.Flags().GetString("userAgent")
+				printImage(results.Hits[0].Strip, ua, logger)
+			}
+			fmt.Println(results.Hits[0].Strip.URL())
 			os.Exit(0)
 		}
-		skipped := 0
-		var validResults []*search.DocumentMatch
-		for _, result := range searchResult.Hits {
-			if result.Score >= minScore {
-				validResults = append(validResults, result)
-			} else {
-				skipped++
-			}
+		if len(results.Hits) == 0 {
+			fmt.Printf("No more results: there are %d results above the threshold.\n", results.Above)
+			os.Exit(1)
+		}
+		if opts.Explain {
+			printWeights(opts)
 		}
 		fmt.Println("Your search results:")
-		for pos, result := range validResults {
-			strip := database.NewStripFromDb(result)
-			fmt.Printf("%d - (%.2f) %s", pos+1, result.Score, strip.Summary())
+		for pos, hit := range results.Hits {
+			printSummary(tmpl, output.NewHitSummary(opts.From+pos+1, hit))
+			if opts.Explain {
+				printMatched(opts, hit)
+			}
+		}
+		fmt.Printf("Showing results %d–%d of %d\n", opts.From+1, opts.From+len(results.Hits), results.Above)
+		if skipped := results.Total - results.Above; skipped > 0 {
+			fmt.Printf("We also found %d results below the threshold (%.2f)\n", skipped, opts.MinScore)
 		}
-		if skipped > 0 {
-			fmt.Printf("We also found %d results below the threshold (%.2f)\n", skipped, minScore)
+		if facets != nil {
+			printFacets(facets)
 		}
 	},
 }
 
+// printSearch prints the results of a search in one of the machine formats.
+func printSearch(format output.Format, queryStr string, opts *database.SearchOpts, results *database.SearchResults, facets *database.Facets) {
+	doc := output.NewSearch(queryStr, opts, results)
+	doc.Facets = facets
+	var err error
+	if format.Tabular() {
+		err = writeRecords(format, output.ResultColumns, func(w *output.Writer) error {
+			for _, result := range doc.Results {
+				if err := w.Write(result); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	} else {
+		err = output.Encode(os.Stdout, format, doc)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// printFacets shows the distribution of the search results.
+func printFacets(facets *database.Facets) {
+	fmt.Printf("Distribution of all %d results:\n", facets.Total)
+	years := make([]int, 0, len(facets.Years))
+	for year := range facets.Years {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+	fmt.Println("  by year:")
+	for _, year := range years {
+		fmt.Printf("\t%d: %d\n", year, facets.Years[year])
+	}
+	fmt.Println("  by field matched:")
+	for _, field := range []string{"title", "alt", "transcript", "notes"} {
+		fmt.Printf("\t%s: %d\n", field, facets.Fields[field])
+	}
+}
+
+// searchBoosts returns the weights of the fields: the defaults, overridden by
+// the configuration, overridden by the command line.
+func searchBoosts(cmd *cobra.Command) (map[string]float64, error) {
+	boosts := make(map[string]float64)
+	for field, weight := range database.DefaultBoosts {
+		boosts[field] = weight
+	}
+	configured, err := database.ParseBoosts(viper.GetStringMapString("boosts"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid boosts in the configuration: %s", err)
+	}
+	flags, _ := cmd.Flags().GetStringToString("boost")
+	requested, err := database.ParseBoosts(flags)
+	if err != nil {
+		return nil, err
+	}
+	for _, override := range []map[string]float64{configured, requested} {
+		for field, weight := range override {
+			boosts[field] = weight
+		}
+	}
+	return boosts, nil
+}
+
+// printWeights shows the weights of the fields used in a search.
+func printWeights(opts *database.SearchOpts) {
+	if opts.Raw {
+		fmt.Println("Field weights are not applied to raw queries.")
+		return
+	}
+	fields := make([]string, 0, len(opts.Boosts))
+	for field := range opts.Boosts {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	weights := make([]string, len(fields))
+	for i, field := range fields {
+		weights[i] = fmt.Sprintf("%s=%g", field, opts.Weight(field))
+	}
+	fmt.Printf("Field weights: %s (other fields: 1)\n", strings.Join(weights, ", "))
+}
+
+// printMatched shows the fields a hit matched in, with their weight.
+func printMatched(opts *database.SearchOpts, hit *database.Hit) {
+	matched := make([]string, len(hit.Matched))
+	for i, field := range hit.Matched {
+		matched[i] = fmt.Sprintf("%s (×%g)", field, opts.Weight(field))
+	}
+	fmt.Printf("\tmatched: %s\n", strings.Join(matched, ", "))
+}
+
+// searchOpts builds the search options from the command line flags.
+func searchOpts(cmd *cobra.Command) (*database.SearchOpts, error) {
+	opts := *database.DefaultSearchOpts
+	now := time.Now()
+	if cmd.Flags().Changed("year") && (cmd.Flags().Changed("since") || cmd.Flags().Changed("until")) {
+		return nil, fmt.Errorf("--year can't be used with --since or --until")
+	}
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		start, _, err := database.ParseDateRange(since, now)
+		if err != nil {
+			return nil, err
+		}
+		opts.Since = start
+	}
+	if until, _ := cmd.Flags().GetString("until"); until != "" {
+		_, end, err := database.ParseDateRange(until, now)
+		if err != nil {
+			return nil, err
+		}
+		opts.Until = end
+	}
+	opts.Fuzziness, _ = cmd.Flags().GetInt("fuzzy")
+	boosts, err := searchBoosts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	opts.Boosts = boosts
+	opts.Explain, _ = cmd.Flags().GetBool("explain")
+	opts.Raw, _ = cmd.Flags().GetBool("raw")
+	if opts.Raw && cmd.Flags().Changed("boost") {
+		return nil, fmt.Errorf("--boost can't be used with --raw: boost the terms of the query with ^N, as in title:barrel^5")
+	}
+	limit, _ := cmd.Flags().GetInt("limit")
+	page, _ := cmd.Flags().GetInt("page")
+	offset, _ := cmd.Flags().GetInt("offset")
+	if limit < 1 || page < 1 || offset < 0 {
+		return nil, fmt.Errorf("--limit and --page must be positive, and --offset can't be negative")
+	}
+	opts.MaxRecords = limit
+	opts.From = (page - 1) * limit
+	if cmd.Flags().Changed("offset") {
+		opts.From = offset
+	}
+	// The lucky result is always the best one.
+	if lucky, _ := cmd.Flags().GetBool("lucky"); lucky {
+		opts.MaxRecords = 1
+		opts.From = 0
+	}
+	highlight, _ := cmd.Flags().GetBool("highlight")
+	noHighlight, _ := cmd.Flags().GetBool("no-highlight")
+	switch {
+	case !highlight || noHighlight:
+		opts.Highlight = ""
+	case isTerminal(os.Stdout):
+		opts.Highlight = database.HighlightANSI
+	default:
+		opts.Highlight = database.HighlightMarkers
+	}
+	if year, _ := cmd.Flags().GetInt("year"); year != 0 {
+		start, end, err := database.ParseDateRange(strconv.Itoa(year), now)
+		if err != nil {
+			return nil, err
+		}
+		opts.Since, opts.Until = start, end
+	}
+	return &opts, nil
+}
+
 func init() {
 	rootCmd.AddCommand(searchCmd)
 
@@ -91,4 +331,20 @@ func init() {
 	// is called directly, e.g.:
 	// searchCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	searchCmd.Flags().BoolP("lucky", "l", false, "Return just the url of one result (for IRC usage).")
+	searchCmd.Flags().String("since", "", "Only show strips published since this date (YYYY[-MM[-DD]], or relative like 2y, 6m, 3w, 10d).")
+	searchCmd.Flags().String("until", "", "Only show strips published until this date (YYYY[-MM[-DD]], or relative like 2y, 6m, 3w, 10d).")
+	searchCmd.Flags().Int("year", 0, "Only show strips published in this year.")
+	searchCmd.Flags().Int("fuzzy", 0, "Tolerate up to this many typos in each term of the query.")
+	searchCmd.Flags().Lookup("fuzzy").NoOptDefVal = strconv.Itoa(database.MaxFuzziness)
+	searchCmd.Flags().Bool("highlight", true, "Show the parts of each result matching the query.")
+	searchCmd.Flags().Bool("no-highlight", false, "Don't show the parts of each result matching the query.")
+	searchCmd.Flags().IntP("limit", "n", 10, "Maximum number of results to show.")
+	searchCmd.Flags().Int("page", 1, "Page of results to show.")
+	searchCmd.Flags().Int("offset", 0, "Number of results to skip. Overrides --page.")
+	searchCmd.Flags().Bool("facets", false, "Show how the results are distributed by year and by field matched.")
+	searchCmd.Flags().StringToString("boost", nil, "Weight of the matches in a field, like title=5. Can be repeated.")
+	searchCmd.Flags().Bool("raw", false, "Use the bleve query string syntax, rather than the one of xkcli.")
+	searchCmd.Flags().Bool("image", true, "With --lucky, also show the image of the strip, when printing to a terminal.")
+	searchCmd.Flags().StringP("userAgent", "u", "XKCD-cli Crawler/1.0.0", "The user-agent to use when downloading the image of the strip.")
+	searchCmd.Flags().Bool("explain", false, "Show the weights of the fields, and the fields each result matched in.")
 }
@@ -0,0 +1,119 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/server"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// shutdownTimeout is how long the requests in progress have to finish when
+// the server is stopped.
+const shutdownTimeout = 10 * time.Second
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the database as an HTTP JSON API.",
+	Long: `Share your database with the rest of the team over HTTP. The answers
+are the JSON documents of --output json:
+
+GET /search?q=<query>&limit=&offset=&since=&until=   search the strips
+GET /strips/<id>                                     show a strip
+GET /random?q=<query>&tag=<tag>                      pick a random strip
+GET /latest                                          show the latest strip
+GET /healthz                                         check the database
+
+The database is opened for each request, so you can keep it up to date
+by running xkcli refresh while the server runs.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		requireText(cmd)
+		address := viper.GetString("listenAddress")
+		if cmd.Flags().Changed("address") {
+			address, _ = cmd.Flags().GetString("address")
+		}
+		maxLimit, _ := cmd.Flags().GetInt("max-limit")
+		logger := setupLogging(debugLog).Sugar()
+		defer logger.Sync()
+		database.SetLogger(logger)
+		boosts, err := searchBoosts(cmd)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		opts := &server.Options{
+			Search:        *database.DefaultSearchOpts,
+			FuzzyFallback: viper.GetInt("fuzzyFallback"),
+			FuzzyMinScore: viper.GetFloat64("fuzzyMinScore"),
+			MaxLimit:      maxLimit,
+		}
+		opts.Search.MinScore = viper.GetFloat64("minScore")
+		opts.Search.Boosts = boosts
+		// Check that the database can be served before listening.
+		open := func() (database.Repository, error) {
+			return openRepository(&database.OpenOpts{ReadOnly: true, Timeout: 5 * time.Second})
+		}
+		repo, err := open()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		repo.Close()
+
+		srv := &http.Server{
+			Addr:         address,
+			Handler:      server.New(open, opts, logger),
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 30 * time.Second,
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			stop := make(chan os.Signal, 1)
+			signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+			sig := <-stop
+			logger.Infow("Shutting down", "signal", sig.String())
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.Errorw("Could not shut down cleanly", "error", err)
+			}
+		}()
+		logger.Infow("Serving the database", "address", address, "db", viper.GetString("dbPath"))
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		<-done
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringP("address", "a", "", "Address to listen on, like :8080 to accept connections from everywhere (default listenAddress from the configuration).")
+	serveCmd.Flags().Int("max-limit", 100, "Maximum number of results a search can return.")
+}
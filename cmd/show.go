@@ -0,0 +1,161 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/download"
+	"github.com/lavagetto/xkcli/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a strip in full.",
+	Long: `Show everything we know about a strip, including its full transcript:
+
+xkcli show 327
+
+If the strip is not in the database yet, it's downloaded and added to it.
+On a terminal, the image of the strip is shown too; --image=false disables it.
+Use --field to print just one value, for instance to use it in a script:
+
+xkcli show 327 --field img`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeID,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := parseID(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		field, _ := cmd.Flags().GetString("field")
+		if field != "" {
+			// Check the field before doing any work.
+			if _, err := (database.XKCDStrip{}).Field(field); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		logger := setupLogging(debugLog).Sugar()
+		defer logger.Sync()
+		download.SetLogger(logger)
+		database.SetLogger(logger)
+		ua, _ := cmd.Flags().GetString("userAgent")
+		strip := findStrip(id, ua, logger)
+		if field != "" {
+			value, _ := strip.Field(field)
+			fmt.Println(value)
+			return
+		}
+		if format := outputFormat(); format != output.Text {
+			printStripDoc(format, strip)
+			return
+		}
+		if wantImage(cmd) {
+			printImage(strip, ua, logger)
+		}
+		printStrip(strip)
+	},
+}
+
+// findStrip returns a strip from the database, or downloads it if it's not
+// there, or if there is no database yet.
+func findStrip(id int, ua string, logger *zap.SugaredLogger) *database.XKCDStrip {
+	repo, err := openRepository(&database.OpenOpts{ReadOnly: true})
+	if errors.Is(err, database.ErrNoDatabase) {
+		return downloadStrip(id, ua, logger)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	strip, err := repo.Get(id)
+	repo.Close()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if strip == nil {
+		return downloadStrip(id, ua, logger)
+	}
+	return strip
+}
+
+// downloadStrip downloads a strip missing from the database, and tries to add it.
+func downloadStrip(id int, ua string, logger *zap.SugaredLogger) *database.XKCDStrip {
+	mgr := download.Manager{
+		Bus: make(chan struct{}, 1),
+		Ua:  ua,
+	}
+	defer mgr.Close()
+	w := mgr.Get(id)
+	if w == nil {
+		fmt.Printf("Strip %d is not in the database, and could not be downloaded.\n", id)
+		os.Exit(1)
+	}
+	strip := database.NewStrip(w)
+	// Not being able to save the strip is no reason not to show it.
+	repo, err := openRepository(&database.OpenOpts{Timeout: 2 * time.Second})
+	if err != nil {
+		logger.Warnw("Could not add the strip to the database", "id", id, "error", err)
+		return strip
+	}
+	defer repo.Close()
+	if err := repo.Put(strip); err != nil {
+		logger.Warnw("Could not add the strip to the database", "id", id, "error", err)
+	}
+	return strip
+}
+
+// printStrip shows all the values of a strip.
+func printStrip(strip *database.XKCDStrip) {
+	fmt.Printf("XKCD %d: %s\n", strip.ID, strip.Title)
+	fmt.Printf("Date:       %s\n", strip.Date)
+	fmt.Printf("URL:        %s\n", strip.URL())
+	fmt.Printf("Image:      %s\n", strip.Img)
+	fmt.Printf("Alt text:   %s\n", strip.Comment)
+	if len(strip.Tags) > 0 {
+		fmt.Printf("Tags:       %s\n", strings.Join(strip.Tags, ", "))
+	}
+	if strip.Favourite {
+		fmt.Println("Favourite:  ★")
+	}
+	if len(strip.Notes) > 0 {
+		fmt.Println("Notes:")
+		for _, note := range strip.Notes {
+			fmt.Printf("  - %s\n", note)
+		}
+	}
+	if strip.Transcript != "" {
+		fmt.Printf("Transcript:\n%s\n", strip.Transcript)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+	showCmd.Flags().StringP("field", "f", "", fmt.Sprintf("Only print this value: one of %s.", strings.Join(database.StripFields, ", ")))
+	showCmd.Flags().StringP("userAgent", "u", "XKCD-cli Crawler/1.0.0", "The user-agent to use when downloading the strip.")
+	showCmd.Flags().Bool("image", true, "Show the image of the strip, when printing to a terminal.")
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/download"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeXKCD answers the requests to xkcd.com with the documents it maps the
+// paths to, and 404 for the others.
+type fakeXKCD map[string]string
+
+func (f fakeXKCD) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp := &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Request: r}
+	body, ok := f[r.URL.Path]
+	if !ok {
+		resp.StatusCode, resp.Status = http.StatusNotFound, "404 Not Found"
+	}
+	resp.Body = ioutil.NopCloser(strings.NewReader(body))
+	return resp, nil
+}
+
+// Test strips missing from the database, or with no database at all, are downloaded.
+func TestFindStripMissing(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "xkcli-test")
+	if err != nil {
+		t.Fatalf("Unable to create the temporary directory")
+	}
+	defer os.RemoveAll(tempdir)
+	viper.Set("dbPath", filepath.Join(tempdir, "xkcli.db"))
+	defer viper.Set("dbPath", nil)
+	transport := http.DefaultTransport
+	http.DefaultTransport = fakeXKCD{
+		"/327/info.0.json":  `{"num": 327, "safe_title": "Exploits of a Mom", "year": "2007", "month": "10", "day": "10", "alt": "Her daughter is named Help I'm trapped in a driver's license factory."}`,
+		"/1171/info.0.json": `{"num": 1171, "safe_title": "Perl Problems", "year": "2013", "month": "2", "day": "6", "alt": "To generalize the point about the nature of regular expressions"}`,
+	}
+	defer func() { http.DefaultTransport = transport }()
+	logger := zap.NewNop().Sugar()
+	download.SetLogger(logger)
+	database.SetLogger(logger)
+
+	strip := findStrip(327, "XKCLI/test-suite", logger)
+	assert.Equal(t, "Exploits of a Mom", strip.Title)
+	assert.Equal(t, "2007-10-10", strip.Date)
+	strip = findStrip(1171, "XKCLI/test-suite", logger)
+	assert.Equal(t, "Perl Problems", strip.Title)
+
+	// Both are saved in the database created for the first one.
+	repo, err := openRepository(&database.OpenOpts{ReadOnly: true})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer repo.Close()
+	count, err := repo.Count()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	strip, err = repo.Get(327)
+	assert.Nil(t, err)
+	assert.Equal(t, "Exploits of a Mom", strip.Title)
+}
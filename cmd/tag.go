@@ -0,0 +1,105 @@
+/*
+Copyright © 2020 Giuseppe Lavagetto
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/output"
+	"github.com/spf13/cobra"
+)
+
+// tagCmd represents the tag command
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Manage the tags of strips.",
+	Long: `Tag the strips you use often, to find them later with a search like:
+
+xkcli search tags:security`,
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:               "add <id> <tag>...",
+	Short:             "Add tags to a strip.",
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeTagAdd,
+	Run: func(cmd *cobra.Command, args []string) {
+		updateStrip(args[0], func(strip *database.XKCDStrip) {
+			strip.AddTags(args[1:]...)
+		})
+	},
+}
+
+var tagRmCmd = &cobra.Command{
+	Use:               "rm <id> <tag>...",
+	Short:             "Remove tags from a strip.",
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeTagRm,
+	Run: func(cmd *cobra.Command, args []string) {
+		updateStrip(args[0], func(strip *database.XKCDStrip) {
+			strip.RemoveTags(args[1:]...)
+		})
+	},
+}
+
+// updateStrip applies a change to the user data of a strip, and shows the
+// result. If change is nil, the strip is only shown.
+func updateStrip(arg string, change func(*database.XKCDStrip)) {
+	id, err := parseID(arg)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	format := outputFormat()
+	logger := setupLogging(debugLog).Sugar()
+	defer logger.Sync()
+	database.SetLogger(logger)
+	repo, err := openRepository(&database.OpenOpts{ReadOnly: change == nil})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer repo.Close()
+	strip, err := repo.Get(id)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if strip == nil {
+		fmt.Printf("Strip %d is not in the database, run `xkcli refresh` first.\n", id)
+		os.Exit(1)
+	}
+	if change != nil {
+		change(strip)
+		if err := repo.Put(strip); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if format != output.Text {
+		printStripDoc(format, strip)
+		return
+	}
+	printSummary(summaryTemplate(), output.NewSummary(strip))
+}
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+	tagCmd.AddCommand(tagAddCmd)
+	tagCmd.AddCommand(tagRmCmd)
+}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/lang/en"
+	"github.com/blevesearch/bleve/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/analysis/token/porter"
+	"github.com/blevesearch/bleve/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/registry"
+)
+
+// Names of the analysis components we define in the index mapping.
+const (
+	englishAnalyzer = "xkcli_en"
+	keywordAnalyzer = "xkcli_keyword"
+	synonymFilter   = "xkcli_synonyms"
+)
+
+// analyzerAliases maps the analyzer names users can pick in the configuration
+// to the ones defined in the index mapping. Any other name is passed to bleve as-is.
+var analyzerAliases = map[string]string{
+	"en":      englishAnalyzer,
+	"keyword": keywordAnalyzer,
+}
+
+// AnalysisConfig controls how the text fields are analyzed, both when indexing
+// and when querying.
+type AnalysisConfig struct {
+	// Analyzers maps field names to the name of their analyzer.
+	Analyzers map[string]string
+	// Synonyms is a list of groups of equivalent terms.
+	Synonyms [][]string
+}
+
+// DefaultAnalysis uses english stemming and stop words on the prose fields,
+// and indexes the full title as a single lowercase term in title_exact.
+var DefaultAnalysis = &AnalysisConfig{
+	Analyzers: map[string]string{
+		"title":       "en",
+		"title_exact": "keyword",
+		"comment":     "en",
+		"transcript":  "en",
+		"notes":       "en",
+	},
+}
+
+// analyzerFor returns the name of the analyzer to use for a field.
+func (a *AnalysisConfig) analyzerFor(field string) string {
+	name, ok := a.Analyzers[field]
+	if !ok {
+		name = DefaultAnalysis.Analyzers[field]
+	}
+	if alias, ok := analyzerAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// fingerprint returns a string that changes whenever the configuration would
+// produce a different index.
+func (a *AnalysisConfig) fingerprint() string {
+	fields := make([]string, 0, len(DefaultAnalysis.Analyzers))
+	for field := range DefaultAnalysis.Analyzers {
+		fields = append(fields, field+"="+a.analyzerFor(field))
+	}
+	sort.Strings(fields)
+	data, _ := json.Marshal(struct {
+		Analyzers []string
+		Synonyms  [][]string
+	}{fields, a.Synonyms})
+	return string(data)
+}
+
+// addTo defines the custom analyzers in the index mapping.
+func (a *AnalysisConfig) addTo(m *mapping.IndexMappingImpl) error {
+	groups := make([]interface{}, len(a.Synonyms))
+	for i, group := range a.Synonyms {
+		groups[i] = strings.Join(group, ",")
+	}
+	err := m.AddCustomTokenFilter(synonymFilter, map[string]interface{}{
+		"type":     synonymFilter,
+		"synonyms": groups,
+	})
+	if err != nil {
+		return err
+	}
+	err = m.AddCustomAnalyzer(englishAnalyzer, map[string]interface{}{
+		"type":      custom.Name,
+		"tokenizer": unicode.Name,
+		"token_filters": []string{
+			en.PossessiveName,
+			lowercase.Name,
+			synonymFilter,
+			en.StopName,
+			porter.Name,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	err = m.AddCustomAnalyzer(keywordAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []string{lowercase.Name},
+	})
+	if err != nil {
+		return err
+	}
+	// Queries on the _all field are analyzed with the default analyzer, so
+	// it needs to match the one used for the prose fields.
+	m.DefaultAnalyzer = englishAnalyzer
+	return nil
+}
+
+// ReadSynonyms parses a synonyms file. Every non-empty line not starting with #
+// is a comma-separated group of equivalent terms, e.g. "ML, machine learning".
+func ReadSynonyms(r io.Reader) ([][]string, error) {
+	var groups [][]string
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var group []string
+		for _, term := range strings.Split(line, ",") {
+			term = strings.ToLower(strings.Join(strings.Fields(term), " "))
+			if term != "" {
+				group = append(group, term)
+			}
+		}
+		if len(group) < 2 {
+			return nil, fmt.Errorf("line %d: a synonym group needs at least two terms", lineno)
+		}
+		groups = append(groups, group)
+	}
+	return groups, scanner.Err()
+}
+
+// LoadSynonyms reads the synonyms file at path.
+func LoadSynonyms(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSynonyms(f)
+}
+
+// SynonymFilter adds to the token stream all the synonyms of the terms
+// (and sequences of terms) it recognizes, at the same position.
+type SynonymFilter struct {
+	// phrases maps the first word of every known phrase to the phrases
+	// starting with it, and the group they belong to.
+	phrases map[string][]synonymPhrase
+	groups  [][][]string
+}
+
+type synonymPhrase struct {
+	words []string
+	group int
+}
+
+// NewSynonymFilter creates a filter for the groups of synonyms.
+func NewSynonymFilter(groups [][]string) *SynonymFilter {
+	f := SynonymFilter{phrases: make(map[string][]synonymPhrase)}
+	for i, group := range groups {
+		var terms [][]string
+		for _, term := range group {
+			words := strings.Fields(strings.ToLower(term))
+			if len(words) == 0 {
+				continue
+			}
+			terms = append(terms, words)
+			f.phrases[words[0]] = append(f.phrases[words[0]], synonymPhrase{words, i})
+		}
+		f.groups = append(f.groups, terms)
+	}
+	return &f
+}
+
+// Filter implements analysis.TokenFilter.
+func (f *SynonymFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	if len(f.phrases) == 0 {
+		return input
+	}
+	output := make(analysis.TokenStream, 0, len(input))
+	for i, token := range input {
+		output = append(output, token)
+		for _, phrase := range f.phrases[string(token.Term)] {
+			if !f.matches(input[i:], phrase.words) {
+				continue
+			}
+			for _, synonym := range f.groups[phrase.group] {
+				if equalWords(synonym, phrase.words) {
+					continue
+				}
+				for _, word := range synonym {
+					output = append(output, &analysis.Token{
+						Term:     []byte(word),
+						Start:    token.Start,
+						End:      token.End,
+						Position: token.Position,
+						Type:     token.Type,
+					})
+				}
+			}
+		}
+	}
+	return output
+}
+
+func (f *SynonymFilter) matches(input analysis.TokenStream, words []string) bool {
+	if len(input) < len(words) {
+		return false
+	}
+	for i, word := range words {
+		if string(input[i].Term) != word {
+			return false
+		}
+	}
+	return true
+}
+
+func equalWords(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// synonymFilterConstructor builds the filter from the configuration stored in the mapping.
+func synonymFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	var groups [][]string
+	raw, _ := config["synonyms"].([]interface{})
+	for _, item := range raw {
+		line, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid synonym group %v", item)
+		}
+		groups = append(groups, strings.Split(line, ","))
+	}
+	return NewSynonymFilter(groups), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(synonymFilter, synonymFilterConstructor)
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadSynonyms(t *testing.T) {
+	groups, err := ReadSynonyms(strings.NewReader(`
+# comments are ignored
+ML,  Machine   Learning
+tables, sql
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"ml", "machine learning"}, {"tables", "sql"}}, groups)
+	_, err = ReadSynonyms(strings.NewReader("lonely\n"))
+	assert.Error(t, err)
+}
+
+func terms(stream analysis.TokenStream) []string {
+	var result []string
+	for _, token := range stream {
+		result = append(result, string(token.Term))
+	}
+	return result
+}
+
+func tokens(words ...string) analysis.TokenStream {
+	var stream analysis.TokenStream
+	for i, word := range words {
+		stream = append(stream, &analysis.Token{Term: []byte(word), Position: i + 1})
+	}
+	return stream
+}
+
+func TestSynonymFilter(t *testing.T) {
+	f := NewSynonymFilter([][]string{{"ml", "machine learning"}})
+	assert.Equal(t, []string{"ml", "machine", "learning"}, terms(f.Filter(tokens("ml"))))
+	assert.Equal(t,
+		[]string{"about", "machine", "ml", "learning"},
+		terms(f.Filter(tokens("about", "machine", "learning"))),
+	)
+	// Partial phrases are left alone.
+	assert.Equal(t, []string{"machine", "code"}, terms(f.Filter(tokens("machine", "code"))))
+}
+
+// Stemming, stop words and synonyms apply both to indexing and to queries.
+func TestAnalysis(t *testing.T) {
+	tempdir, teardown := schema_setup(t)
+	defer teardown()
+	db, err := OpenWith(path.Join(tempdir, "xkcli.bleve"), &OpenOpts{
+		Analysis: &AnalysisConfig{Synonyms: [][]string{{"ml", "machine learning"}}},
+	})
+	assert.Nil(t, err)
+	defer db.Close()
+	(&XKCDStrip{ID: 1, Title: "Running", Transcript: "the machine learning pile"}).Index(db)
+	(&XKCDStrip{ID: 2, Title: "Exploits of a Mom", Transcript: "bobby tables"}).Index(db)
+	for _, query := range []string{"run", "ML", "machines", "title_exact:\"exploits of a mom\""} {
+		results, err := SearchStr(db, query, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, int(results.Total), "query %s", query)
+	}
+}
@@ -0,0 +1,248 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/blevesearch/bleve"
+)
+
+// backupFormat identifies our backup files.
+const backupFormat = "xkcli-backup/1"
+
+// BackupHeader is the first line of a backup, describing its contents.
+type BackupHeader struct {
+	Format        string    `json:"format"`
+	SchemaVersion int       `json:"schemaVersion"`
+	Created       time.Time `json:"created"`
+	Documents     int       `json:"documents"`
+}
+
+// backupTrailer is the last line of a backup, with the checksum of all
+// the lines preceding it.
+type backupTrailer struct {
+	SHA256 string `json:"sha256"`
+}
+
+// snapshot returns all the strips in the index from a single search, so that
+// they are consistent even if the index is being written to.
+func snapshot(idx bleve.Index) ([]*XKCDStrip, error) {
+	count, err := idx.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		// Leave some room for strips added in the meantime.
+		size := int(count) + 100
+		request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), size, 0, false)
+		request.Fields = allFields
+		request.SortBy([]string{"id", "_id"})
+		results, err := idx.Search(request)
+		if err != nil {
+			return nil, err
+		}
+		if int(results.Total) > size {
+			count = results.Total
+			continue
+		}
+		strips := make([]*XKCDStrip, 0, len(results.Hits))
+		for _, hit := range results.Hits {
+			if strip := NewStripFromDb(hit); strip != nil {
+				strips = append(strips, strip)
+			}
+		}
+		return strips, nil
+	}
+}
+
+// Backup writes all the strips in the index and its metadata to w, as a gzip-compressed
+// stream of JSON lines: a header, one line per strip, and a trailer with the checksum.
+// It returns the number of strips written.
+func Backup(idx bleve.Index, w io.Writer) (int, error) {
+	version, err := GetSchemaVersion(idx)
+	if err != nil {
+		return 0, err
+	}
+	strips, err := snapshot(idx)
+	if err != nil {
+		return 0, err
+	}
+	gz := gzip.NewWriter(w)
+	checksum := sha256.New()
+	encoder := json.NewEncoder(io.MultiWriter(gz, checksum))
+	header := BackupHeader{
+		Format:        backupFormat,
+		SchemaVersion: version,
+		Created:       time.Now().UTC(),
+		Documents:     len(strips),
+	}
+	if err := encoder.Encode(header); err != nil {
+		return 0, err
+	}
+	for _, strip := range strips {
+		if err := encoder.Encode(strip); err != nil {
+			return 0, err
+		}
+	}
+	trailer := backupTrailer{SHA256: hex.EncodeToString(checksum.Sum(nil))}
+	if err := json.NewEncoder(gz).Encode(trailer); err != nil {
+		return 0, err
+	}
+	return len(strips), gz.Close()
+}
+
+// ReadBackup reads a backup, verifying its checksum.
+func ReadBackup(r io.Reader) (*BackupHeader, []*XKCDStrip, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a valid backup: %s", err)
+	}
+	defer gz.Close()
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, nil, fmt.Errorf("the backup is damaged: %s", err)
+	}
+	// The trailer is the last line, everything before it is checksummed.
+	trimmed := bytes.TrimRight(data, "\n")
+	cut := bytes.LastIndexByte(trimmed, '\n')
+	if cut < 0 {
+		return nil, nil, fmt.Errorf("the backup is truncated")
+	}
+	body, last := trimmed[:cut+1], trimmed[cut+1:]
+	var trailer backupTrailer
+	if err := json.Unmarshal(last, &trailer); err != nil || trailer.SHA256 == "" {
+		return nil, nil, fmt.Errorf("the backup is truncated")
+	}
+	sum := sha256.Sum256(body)
+	if hex.EncodeToString(sum[:]) != trailer.SHA256 {
+		return nil, nil, fmt.Errorf("the checksum of the backup doesn't match, it might be damaged")
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	var header BackupHeader
+	if !scanner.Scan() {
+		return nil, nil, fmt.Errorf("the backup has no header")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Format != backupFormat {
+		return nil, nil, fmt.Errorf("unsupported backup format")
+	}
+	var strips []*XKCDStrip
+	for scanner.Scan() {
+		var strip XKCDStrip
+		if err := json.Unmarshal(scanner.Bytes(), &strip); err != nil {
+			return nil, nil, err
+		}
+		strips = append(strips, &strip)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(strips) != header.Documents {
+		return nil, nil, fmt.Errorf("the backup should contain %d strips, found %d", header.Documents, len(strips))
+	}
+	return &header, strips, nil
+}
+
+// Restore builds a new index from a backup in a temporary directory, and then
+// swaps it in place of the index at path, waiting up to opts.Timeout for the
+// other processes to release it. The index at path is only touched once the
+// new one is complete. It returns the number of strips restored.
+func Restore(r io.Reader, path string, opts *OpenOpts) (int, error) {
+	if opts == nil {
+		opts = &OpenOpts{}
+	}
+	if opts.Analysis == nil {
+		opts.Analysis = DefaultAnalysis
+	}
+	header, strips, err := ReadBackup(r)
+	if err != nil {
+		return 0, err
+	}
+	if header.SchemaVersion > SchemaVersion() {
+		return 0, fmt.Errorf("the backup has schema version %d, but this version of xkcli only supports up to %d",
+			header.SchemaVersion, SchemaVersion())
+	}
+	// The temporary directory must be on the same filesystem to rename the
+	// new index in place of the current one.
+	tmpdir, err := ioutil.TempDir(filepath.Dir(path), filepath.Base(path)+".restoring")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmpdir)
+	newPath := filepath.Join(tmpdir, "index")
+	m, err := NewIndexMapping(opts.Analysis)
+	if err != nil {
+		return 0, err
+	}
+	idx, err := bleve.New(newPath, m)
+	if err != nil {
+		return 0, err
+	}
+	pending, _ := pendingMigrations(header.SchemaVersion)
+	batch := idx.NewBatch()
+	for i, strip := range strips {
+		for _, m := range pending {
+			if m.Transform != nil {
+				m.Transform(strip)
+			}
+		}
+		if err := strip.AddTo(batch); err != nil {
+			idx.Close()
+			return 0, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(strips))
+		}
+	}
+	if err := idx.Batch(batch); err != nil {
+		idx.Close()
+		return 0, err
+	}
+	if err := stampIndex(idx, opts.Analysis); err != nil {
+		idx.Close()
+		return 0, err
+	}
+	if err := idx.Close(); err != nil {
+		return 0, err
+	}
+	// Keep the other processes from writing to the current index while
+	// it's replaced, or what they write would be lost.
+	current, err := lockIndex(path, opts.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	if current != nil {
+		defer current.Close()
+	}
+	return len(strips), swapIndex(newPath, path)
+}
+
+// lockIndex opens the index at path for writing, which keeps the other
+// processes from opening it until it's closed. It returns nil if there is
+// no index to lock.
+func lockIndex(path string, timeout time.Duration) (bleve.Index, error) {
+	idx, err := openTimeout(timeout, func() (bleve.Index, error) {
+		return bleve.Open(path)
+	})
+	switch {
+	case err == bleve.ErrorIndexPathDoesNotExist:
+		return nil, nil
+	case err == ErrLocked:
+		return nil, err
+	case err != nil:
+		// Nobody can be using an index that can't be opened.
+		logger.Warnw("Could not open the current index, replacing it anyway", "path", path, "error", err)
+		return nil, nil
+	}
+	return idx, nil
+}
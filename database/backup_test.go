@@ -0,0 +1,73 @@
+package database
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupRestore(t *testing.T) {
+	tempdir, teardown := schema_setup(t)
+	defer teardown()
+	db, err := Open(path.Join(tempdir, "source.bleve"))
+	assert.Nil(t, err)
+	for i := 1; i <= 3; i++ {
+		(&XKCDStrip{ID: i, Title: "strip", Img: "img.png", Date: "2010-01-01", Transcript: "full transcript"}).Index(db)
+	}
+	var buf bytes.Buffer
+	count, err := Backup(db, &buf)
+	db.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+	archive := buf.Bytes()
+
+	header, strips, err := ReadBackup(bytes.NewReader(archive))
+	assert.Nil(t, err)
+	assert.Equal(t, SchemaVersion(), header.SchemaVersion)
+	assert.Equal(t, 3, len(strips))
+	assert.Equal(t, "full transcript", strips[2].Transcript)
+
+	// Restore over an existing index.
+	target := path.Join(tempdir, "target.bleve")
+	db, err = Open(target)
+	assert.Nil(t, err)
+	(&XKCDStrip{ID: 100, Title: "to be replaced"}).Index(db)
+	// An index in use is not replaced.
+	_, err = Restore(bytes.NewReader(archive), target, &OpenOpts{Timeout: 100 * time.Millisecond})
+	assert.Equal(t, ErrLocked, err)
+	db.Close()
+	count, err = Restore(bytes.NewReader(archive), target, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+	_, err = os.Stat(target + ".old")
+	assert.True(t, os.IsNotExist(err))
+	repo, err := OpenRepository(target, nil)
+	assert.Nil(t, err)
+	defer repo.Close()
+	latest, _ := repo.LatestID()
+	assert.Equal(t, 3, latest)
+	strip, _ := repo.Get(2)
+	assert.Equal(t, "2010-01-01", strip.Date)
+}
+
+func TestReadBackupDamaged(t *testing.T) {
+	tempdir, teardown := schema_setup(t)
+	defer teardown()
+	db, err := Open(path.Join(tempdir, "source.bleve"))
+	assert.Nil(t, err)
+	defer db.Close()
+	(&XKCDStrip{ID: 1, Title: "strip"}).Index(db)
+	var buf bytes.Buffer
+	_, err = Backup(db, &buf)
+	assert.Nil(t, err)
+	archive := buf.Bytes()
+	// A truncated archive is refused.
+	_, _, err = ReadBackup(bytes.NewReader(archive[:len(archive)/2]))
+	assert.Error(t, err)
+	_, _, err = ReadBackup(bytes.NewReader([]byte("not a backup")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// boostFields maps the names the user can give a weight to, to their field.
+var boostFields = map[string]string{
+	"title":      "title",
+	"comment":    "comment",
+	"alt":        "comment",
+	"transcript": "transcript",
+	"notes":      "notes",
+	"tags":       "tags",
+}
+
+// DefaultBoosts are the weights of the matches in each field: a title
+// matching is usually more telling than a line of the transcript.
+var DefaultBoosts = map[string]float64{
+	"title":      3,
+	"comment":    1.5,
+	"transcript": 1,
+	"notes":      2,
+}
+
+// ParseBoosts validates the weights given by the user for each field, as in
+// {"title": "3", "alt": "0.5"}. Weights are relative to the default of 1:
+// lower weights make a field count less than the others.
+func ParseBoosts(values map[string]string) (map[string]float64, error) {
+	boosts := make(map[string]float64, len(values))
+	for name, value := range values {
+		field, ok := boostFields[name]
+		if !ok {
+			return nil, fmt.Errorf("can't boost the field %q: use one of title, alt, transcript, notes or tags", name)
+		}
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("invalid weight %q for %s: it must be a positive number", value, name)
+		}
+		boosts[field] = weight
+	}
+	return boosts, nil
+}
+
+// Weight returns the weight of the matches in a field.
+func (s SearchOpts) Weight(field string) float64 {
+	if weight, ok := s.Boosts[field]; ok {
+		return weight
+	}
+	return 1
+}
+
+// boostQuery adds weight to the matches of the query in the boosted fields.
+// All fields are searched with a weight of 1 as part of _all already, so a
+// field with weight w is matched again on its own with boost w-1. Matches
+// can't weigh less than that, so weights lower than 1 are obtained by scaling
+// up all the others.
+func boostQuery(q query.Query, queryStr string, opts *SearchOpts) query.Query {
+	if strings.TrimSpace(queryStr) == "" {
+		return q
+	}
+	seen := make(map[string]bool)
+	var fields []string
+	scale := 1.0
+	for _, field := range boostFields {
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+		fields = append(fields, field)
+		if w := opts.Weight(field); 1/w > scale {
+			scale = 1 / w
+		}
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		extra := opts.Weight(field)*scale - 1
+		if extra <= 0 {
+			continue
+		}
+		match := bleve.NewMatchQuery(queryStr)
+		match.SetField(field)
+		match.SetFuzziness(opts.Fuzziness)
+		match.SetBoost(extra)
+		// Nesting the optional matches one by one, rather than adding them all
+		// to a disjunction, avoids scaling them by the fraction of fields matched.
+		boosted := bleve.NewBooleanQuery()
+		boosted.AddMust(q)
+		boosted.AddShould(match)
+		q = boosted
+	}
+	return q
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBoosts(t *testing.T) {
+	boosts, err := ParseBoosts(map[string]string{"title": "5", "alt": "1.5"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]float64{"title": 5, "comment": 1.5}, boosts)
+	_, err = ParseBoosts(map[string]string{"img": "2"})
+	assert.NotNil(t, err)
+	boosts, err = ParseBoosts(map[string]string{"transcript": "0.5"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]float64{"transcript": 0.5}, boosts)
+	_, err = ParseBoosts(map[string]string{"title": "0"})
+	assert.NotNil(t, err)
+	_, err = ParseBoosts(map[string]string{"title": "lots"})
+	assert.NotNil(t, err)
+}
+
+func TestSearchStrBoosts(t *testing.T) {
+	setup()
+	defer teardown()
+	inTitle := &XKCDStrip{ID: 1, Title: "Barrel", Transcript: "A boy sits in a floating container."}
+	inTranscript := &XKCDStrip{ID: 2, Title: "Petit Trees", Transcript: "A barrel of sap rolls down the hill, and nobody catches it."}
+	inTitle.Index(fixturedb)
+	inTranscript.Index(fixturedb)
+
+	opts := *DefaultSearchOpts
+	opts.Boosts = map[string]float64{"transcript": 10}
+	opts.Explain = true
+	results, err := SearchStr(fixturedb, "barrel", &opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "2", results.Hits[0].ID)
+	assert.Equal(t, []string{"transcript"}, matchedFields(results.Hits[0]))
+
+	opts.Boosts = map[string]float64{"title": 10}
+	results, err = SearchStr(fixturedb, "barrel", &opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", results.Hits[0].ID)
+	assert.Equal(t, []string{"title"}, matchedFields(results.Hits[0]))
+
+	// A field can weigh less than the others.
+	opts.Boosts = map[string]float64{"title": 0.1}
+	results, err = SearchStr(fixturedb, "barrel", &opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "2", results.Hits[0].ID)
+
+	// Raw queries are left alone.
+	q, err := buildQuery(fixturedb, "+title:barrel -tree", &SearchOpts{Raw: true, Boosts: map[string]float64{"title": 10}})
+	assert.Nil(t, err)
+	assert.IsType(t, &query.QueryStringQuery{}, q)
+}
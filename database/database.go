@@ -1,16 +1,47 @@
 package database
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/search/query"
 	"go.uber.org/zap"
 )
 
 var logger *zap.SugaredLogger
 
+// MaxFuzziness is the maximum edit distance supported in fuzzy searches.
+const MaxFuzziness = 2
+
 type SearchOpts struct {
 	Fields     []string
 	SortBy     []string
 	MaxRecords int
+	// From is the number of results to skip, for pagination.
+	From int
+	// Since and Until restrict the results to strips published in [Since, Until).
+	// The zero value leaves the interval open on that side.
+	Since time.Time
+	Until time.Time
+	// Fuzziness is the maximum edit distance allowed between each term of the
+	// query and the indexed terms. Zero means the query is parsed strictly.
+	Fuzziness int
+	// Highlight is the style used to highlight the matches in the results.
+	// If empty, no highlighting is performed.
+	Highlight string
+	// MinScore is the minimum score of the hits returned by Repository.Search.
+	MinScore float64
+	// Boosts are the weights of the matches in each field; fields not
+	// listed weigh 1.
+	Boosts map[string]float64
+	// Explain records which fields each hit matched in.
+	Explain bool
+	// Raw means the query uses the bleve query string syntax, rather than ours.
+	// Boosts are not applied to raw queries.
+	Raw bool
 }
 
 func (s SearchOpts) Apply(request *bleve.SearchRequest) {
@@ -23,6 +54,24 @@ func (s SearchOpts) Apply(request *bleve.SearchRequest) {
 	if s.MaxRecords != 0 {
 		request.Size = s.MaxRecords
 	}
+	request.From = s.From
+	request.IncludeLocations = s.Explain
+	if s.Highlight != "" {
+		request.Highlight = bleve.NewHighlightWithStyle(s.Highlight)
+		for _, field := range highlightFields {
+			request.Highlight.AddField(field)
+		}
+	}
+}
+
+// Filter restricts the query according to the search options.
+func (s SearchOpts) Filter(q query.Query) query.Query {
+	if s.Since.IsZero() && s.Until.IsZero() {
+		return q
+	}
+	dates := bleve.NewDateRangeQuery(s.Since, s.Until)
+	dates.SetField("date")
+	return bleve.NewConjunctionQuery(q, dates)
 }
 
 // SetLogger sets the logger for the package
@@ -30,16 +79,135 @@ func SetLogger(l *zap.SugaredLogger) {
 	logger = l
 }
 
-// Open returns a new database if none exists, or the existing one.
+// OpenOpts controls how the database is opened.
+type OpenOpts struct {
+	// ReadOnly opens an existing database without write access. Databases
+	// that need to be rebuilt to be migrated are refused.
+	ReadOnly bool
+	// Progress, if not nil, is called after each document is copied when
+	// the database is rebuilt during a migration.
+	Progress func(done, total int)
+	// Analysis controls how text is analyzed. If nil, DefaultAnalysis is used.
+	// Changing it causes the database to be rebuilt.
+	Analysis *AnalysisConfig
+	// Timeout, if not zero, is how long to wait for another process to release
+	// the database before giving up with ErrLocked.
+	Timeout time.Duration
+}
+
+// ErrLocked is returned when the database is still in use by another process
+// after the timeout requested when opening it.
+var ErrLocked = errors.New("the database is locked by another process")
+
+// ErrNoDatabase is returned when opening read-only a database that doesn't
+// exist yet.
+var ErrNoDatabase = errors.New("no database found")
+
+// NewIndexMapping returns the index mapping for new databases.
+func NewIndexMapping(a *AnalysisConfig) (*mapping.IndexMappingImpl, error) {
+	if a == nil {
+		a = DefaultAnalysis
+	}
+	m := bleve.NewIndexMapping()
+	if err := a.addTo(m); err != nil {
+		return nil, err
+	}
+	m.AddDocumentMapping("xkcd", DocMapping(a))
+	return m, m.Validate()
+}
+
+// Open returns a new database if none exists, or the existing one, migrated
+// to the current schema version.
 func Open(path string) (bleve.Index, error) {
+	return OpenWith(path, nil)
+}
+
+// OpenWith opens the database according to the options.
+func OpenWith(path string, opts *OpenOpts) (bleve.Index, error) {
+	if opts == nil {
+		opts = &OpenOpts{}
+	}
+	if opts.Analysis == nil {
+		opts.Analysis = DefaultAnalysis
+	}
+	return openTimeout(opts.Timeout, func() (bleve.Index, error) {
+		return open(path, opts)
+	})
+}
+
+// openTimeout calls open, giving up with ErrLocked after timeout, if not zero.
+func openTimeout(timeout time.Duration, open func() (bleve.Index, error)) (bleve.Index, error) {
+	if timeout == 0 {
+		return open()
+	}
+	type opened struct {
+		idx bleve.Index
+		err error
+	}
+	done := make(chan opened, 1)
+	go func() {
+		idx, err := open()
+		done <- opened{idx, err}
+	}()
+	select {
+	case o := <-done:
+		return o.idx, o.err
+	case <-time.After(timeout):
+		// Don't leave the database open if we manage to open it later.
+		go func() {
+			if o := <-done; o.idx != nil {
+				o.idx.Close()
+			}
+		}()
+		return nil, ErrLocked
+	}
+}
+
+func open(path string, opts *OpenOpts) (bleve.Index, error) {
+	if opts.ReadOnly {
+		return openReadOnly(path, opts)
+	}
 	idx, err := bleve.Open(path)
-	if err != nil {
+	if err == bleve.ErrorIndexPathDoesNotExist {
 		logger.Infow("Creating the database", "path", path)
-		mapping := bleve.NewIndexMapping()
-		mapping.AddDocumentMapping("xkcd", DocMapping())
-		idx, err = bleve.New(path, mapping)
+		m, err := NewIndexMapping(opts.Analysis)
+		if err != nil {
+			return nil, err
+		}
+		idx, err = bleve.New(path, m)
+		if err != nil {
+			return nil, err
+		}
+		return idx, stampIndex(idx, opts.Analysis)
 	}
-	return idx, err
+	if err != nil {
+		return nil, err
+	}
+	return migrate(idx, path, opts)
+}
+
+func openReadOnly(path string, opts *OpenOpts) (bleve.Index, error) {
+	idx, err := bleve.OpenUsing(path, map[string]interface{}{"read_only": true})
+	if err == bleve.ErrorIndexPathDoesNotExist {
+		return nil, fmt.Errorf("%w at %s: run `xkcli refresh` to create it", ErrNoDatabase, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	version, err := GetSchemaVersion(idx)
+	if err != nil {
+		idx.Close()
+		return nil, err
+	}
+	// Migrations that don't change the mapping can wait for the next write.
+	if _, rebuild := pendingMigrations(version); rebuild {
+		idx.Close()
+		return nil, ErrOutdatedSchema{Path: path, Found: version, Current: SchemaVersion()}
+	}
+	if changed, _ := analysisChanged(idx, opts.Analysis); changed {
+		logger.Warnw("The analysis configuration changed, run `xkcli refresh` to apply it", "path", path)
+	}
+	return idx, nil
 }
 
 // GetAll fetches all records, according to the search options
@@ -67,6 +235,7 @@ func GetLatestID(idx bleve.Index) int {
 	// If errors occur getting the document from the database, we just return 0
 	// as we assume the database is corrupted.
 	if latest == nil {
+		logger.Warnw("The database might be corrupted, run `xkcli doctor` to check it")
 		return 0
 	}
 	return latest.ID
@@ -89,15 +258,87 @@ func GetAllIDs(idx bleve.Index, maxID int) map[int]bool {
 	return results
 }
 
+// buildQuery returns the bleve query corresponding to the user query.
+func buildQuery(idx bleve.Index, queryStr string, opts *SearchOpts) (query.Query, error) {
+	var q query.Query
+	var parsed parsedQuery
+	text := queryStr
+	if opts.Raw {
+		qs := bleve.NewQueryStringQuery(queryStr)
+		// Report syntax errors now, rather than when searching.
+		if _, err := qs.Parse(); err != nil {
+			return nil, fmt.Errorf("invalid query: %s", err)
+		}
+		q = qs
+	} else {
+		var err error
+		parsed, err = parseQuery(queryStr)
+		if err != nil {
+			return nil, err
+		}
+		q = parsed.query()
+		text = parsed.text()
+	}
+	if opts.Fuzziness < 0 || opts.Fuzziness > MaxFuzziness {
+		return nil, fmt.Errorf("the fuzziness must be between 0 and %d, got %d", MaxFuzziness, opts.Fuzziness)
+	}
+	switch {
+	case opts.Fuzziness > 0 && opts.Raw:
+		// The terms of raw queries are not known: any of their words can match.
+		var err error
+		q, err = fuzzyQuery(idx, text, opts.Fuzziness)
+		if err != nil {
+			return nil, err
+		}
+	case opts.Fuzziness > 0:
+		q = parsed.fuzzyQuery(opts.Fuzziness)
+	}
+	// The fields and operators of raw queries can't be reused as the text to
+	// match in each field: they can boost their terms with ^N instead.
+	if !opts.Raw {
+		q = boostQuery(q, text, opts)
+	}
+	return opts.Filter(q), nil
+}
+
+// fuzzyQuery matches any of the words of a query, allowing for typos.
+func fuzzyQuery(idx bleve.Index, queryStr string, fuzziness int) (query.Query, error) {
+	// Fuzzy queries are not analyzed, so we analyze the terms ourselves to compare
+	// them with what is in the index.
+	m := idx.Mapping()
+	analyzer := m.AnalyzerNamed(m.AnalyzerNameForPath("_all"))
+	if analyzer == nil {
+		return nil, fmt.Errorf("could not find the analyzer for the index")
+	}
+	var terms []query.Query
+	seen := make(map[string]bool)
+	for _, token := range analyzer.Analyze([]byte(queryStr)) {
+		term := string(token.Term)
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		fq := bleve.NewFuzzyQuery(term)
+		fq.SetFuzziness(fuzziness)
+		terms = append(terms, fq)
+	}
+	if len(terms) == 0 {
+		return bleve.NewMatchNoneQuery(), nil
+	}
+	return bleve.NewDisjunctionQuery(terms...), nil
+}
+
 // SearchStr will perform a string query on the datastore
 func SearchStr(idx bleve.Index, queryStr string, opts *SearchOpts) (*bleve.SearchResult, error) {
-	query := bleve.NewQueryStringQuery(queryStr)
-	search := bleve.NewSearchRequest(query)
-	if opts != nil {
-		opts.Apply(search)
-	} else {
-		DefaultSearchOpts.Apply(search)
+	if opts == nil {
+		opts = DefaultSearchOpts
+	}
+	q, err := buildQuery(idx, queryStr, opts)
+	if err != nil {
+		return nil, err
 	}
+	search := bleve.NewSearchRequest(q)
+	opts.Apply(search)
 	searchResults, err := idx.Search(search)
 	if err != nil {
 		logger.Errorw("Error querying the search index", "error", err)
@@ -106,6 +347,38 @@ func SearchStr(idx bleve.Index, queryStr string, opts *SearchOpts) (*bleve.Searc
 	return searchResults, nil
 }
 
+// CountAbove returns the number of hits of a string query scoring at least minScore.
+func CountAbove(idx bleve.Index, queryStr string, opts *SearchOpts, minScore float64) (int, error) {
+	if opts == nil {
+		opts = DefaultSearchOpts
+	}
+	q, err := buildQuery(idx, queryStr, opts)
+	if err != nil {
+		return 0, err
+	}
+	pageSize := 1000
+	count := 0
+	// Hits are sorted by score, so we can stop at the first one below the threshold.
+	for from := 0; ; from += pageSize {
+		search := bleve.NewSearchRequestOptions(q, pageSize, from, false)
+		results, err := idx.Search(search)
+		if err != nil {
+			return 0, err
+		}
+		for _, hit := range results.Hits {
+			if hit.Score < minScore {
+				return count, nil
+			}
+			count++
+		}
+		if len(results.Hits) < pageSize {
+			return count, nil
+		}
+	}
+}
+
 var DefaultSearchOpts = &SearchOpts{
-	Fields: allFields,
+	Fields:    allFields,
+	Highlight: HighlightMarkers,
+	Boosts:    DefaultBoosts,
 }
@@ -7,6 +7,7 @@ import (
 	"path"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/blevesearch/bleve"
 	"github.com/stretchr/testify/assert"
@@ -78,3 +79,91 @@ func TestGetLatestID(t *testing.T) {
 	defer teardown()
 	assert.Equal(t, 2310, GetLatestID(fixturedb))
 }
+
+// Test SearchStr only returns results in the requested date range
+func TestSearchStrDates(t *testing.T) {
+	setup()
+	defer teardown()
+	opts := *DefaultSearchOpts
+	opts.Since, opts.Until, _ = ParseDateRange("2020-01-03", time.Now())
+	results, err := SearchStr(fixturedb, "strip", &opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, int(results.Total))
+	assert.Equal(t, "2303", results.Hits[0].ID)
+}
+
+// Test fuzzy searches tolerate typos
+func TestSearchStrFuzzy(t *testing.T) {
+	setup()
+	defer teardown()
+	opts := *DefaultSearchOpts
+	results, err := SearchStr(fixturedb, "stirp", &opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, int(results.Total))
+	opts.Fuzziness = 2
+	results, err = SearchStr(fixturedb, "stirp", &opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, int(results.Total))
+	opts.Fuzziness = 3
+	_, err = SearchStr(fixturedb, "stirp", &opts)
+	assert.Error(t, err)
+}
+
+// Test SearchStr highlights the matches
+func TestSearchStrHighlight(t *testing.T) {
+	setup()
+	defer teardown()
+	results, err := SearchStr(fixturedb, "comment", nil)
+	assert.Nil(t, err)
+	fragments := BestFragments(results.Hits[0])
+	assert.Equal(t, 1, len(fragments))
+	assert.Equal(t, "alt", fragments[0].Field)
+	assert.Contains(t, fragments[0].Text, "**Comment** #")
+}
+
+// Test pagination and counting the results above a threshold
+func TestSearchStrPages(t *testing.T) {
+	setup()
+	defer teardown()
+	opts := *DefaultSearchOpts
+	opts.MaxRecords = 4
+	opts.From = 8
+	results, err := SearchStr(fixturedb, "strip", &opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, int(results.Total))
+	assert.Equal(t, 2, len(results.Hits))
+	count, err := CountAbove(fixturedb, "strip", &opts, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, count)
+	count, err = CountAbove(fixturedb, "strip", &opts, results.MaxScore+1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+}
+
+// Test the tags can be searched as a field
+func TestSearchStrTags(t *testing.T) {
+	setup()
+	defer teardown()
+	strip := &XKCDStrip{ID: 327, Title: "Exploits of a Mom", Tags: []string{"sql", "security"}}
+	strip.Index(fixturedb)
+	results, err := SearchStr(fixturedb, "tags:sql", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, int(results.Total))
+	assert.Equal(t, "327", results.Hits[0].ID)
+}
+
+func TestSearchStrNotes(t *testing.T) {
+	setup()
+	defer teardown()
+	strip := &XKCDStrip{ID: 327, Title: "Exploits of a Mom", Notes: []string{"show this to the interns"}}
+	strip.Index(fixturedb)
+	results, err := SearchStr(fixturedb, "interns", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, int(results.Total))
+	unboosted := *DefaultSearchOpts
+	unboosted.Boosts = nil
+	plain, err := SearchStr(fixturedb, "interns", &unboosted)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, int(plain.Total))
+	assert.Greater(t, results.Hits[0].Score, plain.Hits[0].Score)
+}
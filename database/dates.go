@@ -0,0 +1,43 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var relativeDate = regexp.MustCompile(`^(\d+)([dwmy])$`)
+
+// ParseDateRange interprets a date given by the user, returning the time interval
+// [start, end) it refers to. The accepted formats are:
+//   - a year (2013), a month (2013-05) or a day (2013-05-01)
+//   - a time relative to now, in days, weeks, months or years (10d, 2w, 6m, 2y);
+//     in this case start and end coincide.
+func ParseDateRange(value string, now time.Time) (time.Time, time.Time, error) {
+	if match := relativeDate.FindStringSubmatch(value); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		var t time.Time
+		switch match[2] {
+		case "d":
+			t = now.AddDate(0, 0, -n)
+		case "w":
+			t = now.AddDate(0, 0, -7*n)
+		case "m":
+			t = now.AddDate(0, -n, 0)
+		case "y":
+			t = now.AddDate(-n, 0, 0)
+		}
+		return t, t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, t.AddDate(0, 0, 1), nil
+	}
+	if t, err := time.Parse("2006-01", value); err == nil {
+		return t, t.AddDate(0, 1, 0), nil
+	}
+	if t, err := time.Parse("2006", value); err == nil {
+		return t, t.AddDate(1, 0, 0), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q: use YYYY, YYYY-MM, YYYY-MM-DD or a relative time like 2y", value)
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDateRange(t *testing.T) {
+	now := time.Date(2020, 5, 31, 12, 0, 0, 0, time.UTC)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	testCases := []struct {
+		value string
+		start time.Time
+		end   time.Time
+	}{
+		{"2013", day(2013, 1, 1), day(2014, 1, 1)},
+		{"2013-05", day(2013, 5, 1), day(2013, 6, 1)},
+		{"2013-05-01", day(2013, 5, 1), day(2013, 5, 2)},
+		{"2y", now.AddDate(-2, 0, 0), now.AddDate(-2, 0, 0)},
+		{"3w", now.AddDate(0, 0, -21), now.AddDate(0, 0, -21)},
+	}
+	for _, tc := range testCases {
+		start, end, err := ParseDateRange(tc.value, now)
+		assert.Nil(t, err, tc.value)
+		assert.Equal(t, tc.start, start, tc.value)
+		assert.Equal(t, tc.end, end, tc.value)
+	}
+	_, _, err := ParseDateRange("yesterday", now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+)
+
+// Diagnosis describes the problems found in an index.
+type Diagnosis struct {
+	// Documents is the number of documents in the index.
+	Documents int
+	// SchemaVersion is the schema version stored in the index.
+	SchemaVersion int
+	// Outdated is true if the schema needs to be migrated.
+	Outdated bool
+	// MappingDrift is true if the mapping of the index differs from
+	// the one we would create now.
+	MappingDrift bool
+	// Undecodable lists the IDs of the documents that are not strips.
+	Undecodable []string
+	// Incomplete lists the strips missing their title or image.
+	Incomplete []int
+	// InvalidDates lists the strips with a missing or unparsable date.
+	InvalidDates []int
+	// Gaps lists the IDs missing between 1 and the highest ID in the index.
+	Gaps []int
+}
+
+// Healthy returns true if no problems were found.
+func (d *Diagnosis) Healthy() bool {
+	return !d.Outdated && !d.MappingDrift && len(d.Undecodable) == 0 &&
+		len(d.Incomplete) == 0 && len(d.InvalidDates) == 0 && len(d.Gaps) == 0
+}
+
+// Damaged returns the IDs of all the strips that need to be downloaded again.
+func (d *Diagnosis) Damaged() []int {
+	seen := make(map[int]bool)
+	var ids []int
+	for _, id := range append(append([]int{}, d.Incomplete...), d.InvalidDates...) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Diagnose checks the integrity of all the documents in the index, and
+// compares its mapping with the one corresponding to the analysis configuration.
+func Diagnose(idx bleve.Index, analysis *AnalysisConfig) (*Diagnosis, error) {
+	if analysis == nil {
+		analysis = DefaultAnalysis
+	}
+	var d Diagnosis
+	var err error
+	d.SchemaVersion, err = GetSchemaVersion(idx)
+	if err != nil {
+		return nil, err
+	}
+	_, d.Outdated = pendingMigrations(d.SchemaVersion)
+	d.MappingDrift, err = MappingDrift(idx, analysis)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int]bool)
+	maxID := 0
+	err = forEachHit(idx, func(hit *search.DocumentMatch) error {
+		d.Documents++
+		strip := NewStripFromDb(hit)
+		if strip == nil {
+			d.Undecodable = append(d.Undecodable, hit.ID)
+			return nil
+		}
+		ids[strip.ID] = true
+		if strip.ID > maxID {
+			maxID = strip.ID
+		}
+		if strip.Title == "" || strip.Img == "" {
+			d.Incomplete = append(d.Incomplete, strip.ID)
+		}
+		if !validDate(hit) {
+			d.InvalidDates = append(d.InvalidDates, strip.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for id := 1; id < maxID; id++ {
+		if !ids[id] {
+			d.Gaps = append(d.Gaps, id)
+		}
+	}
+	return &d, nil
+}
+
+// validDate checks the stored date of a document can be parsed, and is
+// not before the first strip was published.
+func validDate(hit *search.DocumentMatch) bool {
+	raw, ok := hit.Fields["date"].(string)
+	if !ok {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return false
+	}
+	return t.Year() >= firstYear
+}
+
+// MappingDrift returns true if the mapping stored in the index differs from
+// the one a new index would have.
+func MappingDrift(idx bleve.Index, analysis *AnalysisConfig) (bool, error) {
+	expected, err := NewIndexMapping(analysis)
+	if err != nil {
+		return false, err
+	}
+	expectedJSON, err := json.Marshal(expected)
+	if err != nil {
+		return false, err
+	}
+	actualJSON, err := json.Marshal(idx.Mapping())
+	if err != nil {
+		return false, err
+	}
+	return string(expectedJSON) != string(actualJSON), nil
+}
+
+// CompactIDs formats a sorted list of IDs as ranges, like "1-3, 7, 10-12".
+func CompactIDs(ids []int) string {
+	result := ""
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if result != "" {
+			result += ", "
+		}
+		result += strconv.Itoa(ids[i])
+		if j > i {
+			result += "-" + strconv.Itoa(ids[j])
+		}
+		i = j + 1
+	}
+	return result
+}
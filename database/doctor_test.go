@@ -0,0 +1,41 @@
+package database
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnose(t *testing.T) {
+	tempdir, teardown := schema_setup(t)
+	defer teardown()
+	db, err := Open(path.Join(tempdir, "xkcli.bleve"))
+	assert.Nil(t, err)
+	defer db.Close()
+	(&XKCDStrip{ID: 1, Title: "one", Img: "1.png", Date: "2006-01-01"}).Index(db)
+	(&XKCDStrip{ID: 2, Title: "two", Date: "2006-01-02"}).Index(db)
+	(&XKCDStrip{ID: 5, Title: "five", Img: "5.png"}).Index(db)
+	db.Index("garbage", map[string]interface{}{"title": "not a strip"})
+	db.Index("3", map[string]interface{}{"title": 3.0, "img": "3.png"})
+	d, err := Diagnose(db, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, d.Documents)
+	assert.False(t, d.Outdated)
+	assert.False(t, d.MappingDrift)
+	assert.ElementsMatch(t, []string{"3", "garbage"}, d.Undecodable)
+	assert.Equal(t, []int{2}, d.Incomplete)
+	assert.Equal(t, []int{5}, d.InvalidDates)
+	assert.Equal(t, []int{3, 4}, d.Gaps)
+	assert.Equal(t, []int{2, 5}, d.Damaged())
+	assert.False(t, d.Healthy())
+	// Changing the analysis configuration causes a drift.
+	d, err = Diagnose(db, &AnalysisConfig{Analyzers: map[string]string{"title": "standard"}})
+	assert.Nil(t, err)
+	assert.True(t, d.MappingDrift)
+}
+
+func TestCompactIDs(t *testing.T) {
+	assert.Equal(t, "1-3, 7, 10-11", CompactIDs([]int{1, 2, 3, 7, 10, 11}))
+	assert.Equal(t, "", CompactIDs(nil))
+}
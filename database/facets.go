@@ -0,0 +1,95 @@
+package database
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/blevesearch/bleve"
+)
+
+// The year the first XKCD strip was published.
+const firstYear = 2005
+
+// facetsPageSize is the number of hits whose match locations are loaded at
+// once to count the fields matched.
+var facetsPageSize = 500
+
+// fieldLabels maps the fields we report matches for to their user-facing name.
+var fieldLabels = map[string]string{
+	"title":      "title",
+	"comment":    "alt",
+	"transcript": "transcript",
+	"notes":      "notes",
+}
+
+// Facets describes the distribution of the hits of a search.
+type Facets struct {
+	// Total is the number of hits.
+	Total int `json:"total"`
+	// Years maps each year to the number of hits published in it.
+	Years map[int]int `json:"years"`
+	// Fields maps title, alt and transcript to the number of hits that matched in them.
+	Fields map[string]int `json:"fields"`
+}
+
+// SearchFacets returns the distribution of all the hits of a string query,
+// by year and by field matched.
+func SearchFacets(idx bleve.Index, queryStr string, opts *SearchOpts) (*Facets, error) {
+	if opts == nil {
+		opts = DefaultSearchOpts
+	}
+	facets := Facets{
+		Years:  make(map[int]int),
+		Fields: make(map[string]int),
+	}
+	lastYear := time.Now().Year()
+	years := bleve.NewFacetRequest("date", lastYear-firstYear+1)
+	for year := firstYear; year <= lastYear; year++ {
+		start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		years.AddDateTimeRange(strconv.Itoa(year), start, start.AddDate(1, 0, 0))
+	}
+	q, err := buildQuery(idx, queryStr, opts)
+	if err != nil {
+		return nil, err
+	}
+	search := bleve.NewSearchRequest(q)
+	search.Size = 0
+	search.AddFacet("years", years)
+	result, err := idx.Search(search)
+	if err != nil {
+		return nil, err
+	}
+	facets.Total = int(result.Total)
+	for _, dateRange := range result.Facets["years"].DateRanges {
+		year, err := strconv.Atoi(dateRange.Name)
+		if err == nil && dateRange.Count > 0 {
+			facets.Years[year] = dateRange.Count
+		}
+	}
+	if result.Total == 0 {
+		return &facets, nil
+	}
+	// Bleve can't facet on the fields that matched, so we collect the
+	// match locations of all the hits, a page at a time so that a broad
+	// query doesn't load the whole index.
+	search = bleve.NewSearchRequestOptions(q, facetsPageSize, 0, false)
+	search.IncludeLocations = true
+	search.SortBy([]string{"_id"})
+	for {
+		result, err = idx.Search(search)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range result.Hits {
+			for field := range hit.Locations {
+				if label, ok := fieldLabels[field]; ok {
+					facets.Fields[label]++
+				}
+			}
+		}
+		if len(result.Hits) < facetsPageSize {
+			return &facets, nil
+		}
+		search.SetSearchAfter([]string{result.Hits[len(result.Hits)-1].ID})
+	}
+}
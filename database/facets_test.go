@@ -0,0 +1,32 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchFacets(t *testing.T) {
+	setup()
+	defer teardown()
+	facets, err := SearchFacets(fixturedb, "strip", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, facets.Total)
+	assert.Equal(t, map[int]int{2020: 10}, facets.Years)
+	assert.Equal(t, map[string]int{"title": 10}, facets.Fields)
+	facets, err = SearchFacets(fixturedb, "comment", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"alt": 10}, facets.Fields)
+
+	// The fields matched are counted across pages of hits.
+	pageSize := facetsPageSize
+	facetsPageSize = 3
+	defer func() { facetsPageSize = pageSize }()
+	facets, err = SearchFacets(fixturedb, "strip", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"title": 10}, facets.Fields)
+	facetsPageSize = 5
+	facets, err = SearchFacets(fixturedb, "comment", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"alt": 10}, facets.Fields)
+}
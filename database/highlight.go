@@ -0,0 +1,84 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+	htmlFormat "github.com/blevesearch/bleve/search/highlight/format/html"
+	simpleFragmenter "github.com/blevesearch/bleve/search/highlight/fragmenter/simple"
+	simpleHighlighter "github.com/blevesearch/bleve/search/highlight/highlighter/simple"
+)
+
+// The highlighting styles available for search results.
+const (
+	// HighlightMarkers surrounds the matched terms with **markers**.
+	HighlightMarkers = "xkcli_markers"
+	// HighlightANSI emphasizes the matched terms with ANSI escape sequences.
+	HighlightANSI = "xkcli_ansi"
+)
+
+// highlightFields are the fields we return fragments for.
+var highlightFields = []string{"title", "comment", "transcript"}
+
+// The size of the fragments, in characters.
+const fragmentSize = 120
+
+func defineHighlighter(name, before, after string) {
+	_, err := bleve.Config.Cache.DefineFragmentFormatter(name, map[string]interface{}{
+		"type":   htmlFormat.Name,
+		"before": before,
+		"after":  after,
+	})
+	if err != nil {
+		panic(err)
+	}
+	_, err = bleve.Config.Cache.DefineHighlighter(name, map[string]interface{}{
+		"type":       simpleHighlighter.Name,
+		"fragmenter": "xkcli",
+		"formatter":  name,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func init() {
+	_, err := bleve.Config.Cache.DefineFragmenter("xkcli", map[string]interface{}{
+		"type": simpleFragmenter.Name,
+		"size": float64(fragmentSize),
+	})
+	if err != nil {
+		panic(err)
+	}
+	defineHighlighter(HighlightMarkers, "**", "**")
+	defineHighlighter(HighlightANSI, "\x1b[1m", "\x1b[0m")
+}
+
+// Fragment is a piece of a field of a strip that matched a search,
+// with the matched terms highlighted.
+type Fragment struct {
+	// Field is the user-facing name of the field: title, alt or transcript.
+	Field string `json:"field"`
+	Text  string `json:"text"`
+}
+
+// BestFragments returns the best fragment for every field that matched the search.
+func BestFragments(hit *search.DocumentMatch) []Fragment {
+	var fragments []Fragment
+	for _, field := range highlightFields {
+		// Bleve returns a fragment for fields without matches too.
+		if _, matched := hit.Locations[field]; !matched {
+			continue
+		}
+		fieldFragments := hit.Fragments[field]
+		if len(fieldFragments) == 0 {
+			continue
+		}
+		fragments = append(fragments, Fragment{
+			Field: fieldLabels[field],
+			Text:  strings.Join(strings.Fields(fieldFragments[0]), " "),
+		})
+	}
+	return fragments
+}
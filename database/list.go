@@ -0,0 +1,99 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// listSorts maps the orders strips can be listed in to the bleve sort fields.
+// Titles are sorted by their exact version, as the analyzed one is split in terms.
+var listSorts = map[string][]string{
+	"id":     {"id", "_id"},
+	"-id":    {"-id", "_id"},
+	"date":   {"date", "id"},
+	"-date":  {"-date", "-id"},
+	"title":  {"title_exact", "id"},
+	"-title": {"-title_exact", "id"},
+}
+
+// ListOpts selects the strips to list, and their order.
+type ListOpts struct {
+	// Sort is one of id, date and title, optionally preceded by "-" to
+	// reverse the order. Defaults to id.
+	Sort string
+	// From and To restrict the IDs listed, if not zero. Both are included.
+	From int
+	To   int
+	// MissingTranscript only lists the strips without a transcript.
+	MissingTranscript bool
+}
+
+// listOrder returns the sort order requested by opts, and whether it's reversed.
+func listOrder(opts *ListOpts) (string, bool, error) {
+	order := opts.Sort
+	if order == "" {
+		order = "id"
+	}
+	if _, ok := listSorts[order]; !ok {
+		return "", false, fmt.Errorf("can't sort by %q: use one of id, date or title, optionally preceded by -", opts.Sort)
+	}
+	return strings.TrimPrefix(order, "-"), strings.HasPrefix(order, "-"), nil
+}
+
+// listed returns true if a strip is selected by opts.
+func (opts *ListOpts) listed(strip *XKCDStrip) bool {
+	if opts.From != 0 && strip.ID < opts.From {
+		return false
+	}
+	if opts.To != 0 && strip.ID > opts.To {
+		return false
+	}
+	return !opts.MissingTranscript || strings.TrimSpace(strip.Transcript) == ""
+}
+
+// ListStrips calls fn on all the strips selected by opts, in the requested order.
+// The strips are read from the index a page at a time, so that the whole
+// archive can be listed.
+func ListStrips(idx bleve.Index, opts *ListOpts, fn func(*XKCDStrip) error) error {
+	if opts == nil {
+		opts = &ListOpts{}
+	}
+	if _, _, err := listOrder(opts); err != nil {
+		return err
+	}
+	order := opts.Sort
+	if order == "" {
+		order = "id"
+	}
+	sortBy := listSorts[order]
+	var q query.Query = bleve.NewMatchAllQuery()
+	if opts.From != 0 || opts.To != 0 {
+		var from, to *float64
+		inclusive := true
+		if opts.From != 0 {
+			f := float64(opts.From)
+			from = &f
+		}
+		if opts.To != 0 {
+			t := float64(opts.To)
+			to = &t
+		}
+		ids := bleve.NewNumericRangeInclusiveQuery(from, to, &inclusive, &inclusive)
+		ids.SetField("id")
+		q = ids
+	}
+	return forEachMatch(idx, q, sortBy, func(hit *search.DocumentMatch) error {
+		strip := NewStripFromDb(hit)
+		if strip == nil {
+			return nil
+		}
+		if !opts.listed(strip) {
+			return nil
+		}
+		return fn(strip)
+	})
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListStrips(t *testing.T) {
+	setup()
+	defer teardown()
+	strips := []*XKCDStrip{
+		{ID: 1, Title: "Barrel - Part 1", Date: "2006-01-01", Transcript: "A boy sits in a barrel."},
+		{ID: 327, Title: "Exploits of a Mom", Date: "2007-10-10", Transcript: "Did you really name your son Robert'); DROP TABLE Students;-- ?"},
+		{ID: 1167, Title: "Star Trek into Darkness", Date: "2013-01-30"},
+	}
+	for _, strip := range strips {
+		assert.Nil(t, strip.Index(fixturedb))
+	}
+	list := func(opts *ListOpts) []int {
+		ids := []int{}
+		err := ListStrips(fixturedb, opts, func(strip *XKCDStrip) error {
+			ids = append(ids, strip.ID)
+			return nil
+		})
+		assert.Nil(t, err)
+		return ids
+	}
+	all := list(nil)
+	assert.Len(t, all, 13)
+	assert.Equal(t, []int{1, 327, 1167, 2301}, all[:4])
+	assert.Equal(t, []int{2310, 2309}, list(&ListOpts{Sort: "-id"})[:2])
+	assert.Equal(t, []int{2310, 2309}, list(&ListOpts{Sort: "-date"})[:2])
+	assert.Equal(t, []int{1, 327, 1167, 2301}, list(&ListOpts{Sort: "date"})[:4])
+	byTitle := list(&ListOpts{Sort: "title", To: 2000})
+	assert.Equal(t, []int{1, 327, 1167}, byTitle)
+	assert.Equal(t, []int{1167, 327, 1}, list(&ListOpts{Sort: "-title", To: 2000}))
+	assert.Equal(t, []int{327, 1167, 2301}, list(&ListOpts{From: 327, To: 2301}))
+	assert.Equal(t, []int{2309, 2310}, list(&ListOpts{From: 2309}))
+	missing := list(&ListOpts{MissingTranscript: true})
+	assert.Len(t, missing, 11)
+	assert.NotContains(t, missing, 327)
+
+	err := ListStrips(fixturedb, &ListOpts{Sort: "alt"}, func(*XKCDStrip) error { return nil })
+	assert.NotNil(t, err)
+}
+
+func TestMemoryList(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.Put(&XKCDStrip{ID: 1, Title: "Barrel - Part 1", Date: "2006-01-01", Transcript: "A boy sits in a barrel."})
+	repo.Put(&XKCDStrip{ID: 2, Title: "Petit Trees", Date: "2006-01-01"})
+	repo.Put(&XKCDStrip{ID: 327, Title: "Exploits of a Mom", Date: "2007-10-10"})
+	var lister Lister = repo
+	list := func(opts *ListOpts) []int {
+		ids := []int{}
+		err := lister.List(opts, func(strip *XKCDStrip) error {
+			ids = append(ids, strip.ID)
+			return nil
+		})
+		assert.Nil(t, err)
+		return ids
+	}
+	assert.Equal(t, []int{1, 2, 327}, list(nil))
+	assert.Equal(t, []int{327, 2, 1}, list(&ListOpts{Sort: "-id"}))
+	assert.Equal(t, []int{327, 2, 1}, list(&ListOpts{Sort: "-date"}))
+	assert.Equal(t, []int{1, 327, 2}, list(&ListOpts{Sort: "title"}))
+	assert.Equal(t, []int{2, 327}, list(&ListOpts{From: 2, MissingTranscript: true}))
+	assert.NotNil(t, lister.List(&ListOpts{Sort: "alt"}, func(*XKCDStrip) error { return nil }))
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// MemoryRepository is a Repository keeping the strips in memory. Its search
+// is much simpler than the one of bleve: there is no query syntax, and the
+// score of a strip is the fraction of the terms of the query it contains.
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	strips map[int]XKCDStrip
+}
+
+// NewMemoryRepository returns an empty in-memory repository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{strips: make(map[int]XKCDStrip)}
+}
+
+// Get implements Repository.
+func (r *MemoryRepository) Get(id int) (*XKCDStrip, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	strip, ok := r.strips[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyStrip(&strip), nil
+}
+
+// Put implements Repository.
+func (r *MemoryRepository) Put(strip *XKCDStrip) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strips[strip.ID] = *copyStrip(strip)
+	return nil
+}
+
+// copyStrip returns a copy of a strip sharing no slices with it, so that
+// changing a strip doesn't change the stored one until it's put back.
+func copyStrip(strip *XKCDStrip) *XKCDStrip {
+	c := *strip
+	c.Tags = append([]string(nil), strip.Tags...)
+	c.Notes = append([]string(nil), strip.Notes...)
+	return &c
+}
+
+// Delete implements Repository.
+func (r *MemoryRepository) Delete(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.strips, id)
+	return nil
+}
+
+// words splits a text in lowercase words.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
+	})
+}
+
+// score returns the fraction of the terms found in the strip, weighted by the
+// fields they are found in, and the fields matched.
+func (x *XKCDStrip) score(terms []string, opts *SearchOpts) (float64, []string) {
+	texts := map[string]string{
+		"title":      x.Title,
+		"comment":    x.Comment,
+		"transcript": x.Transcript,
+		"tags":       strings.Join(x.Tags, " "),
+		"notes":      strings.Join(x.Notes, " "),
+	}
+	found := make(map[string]map[string]bool)
+	for field, text := range texts {
+		for _, word := range words(text) {
+			if found[word] == nil {
+				found[word] = make(map[string]bool)
+			}
+			found[word][field] = true
+		}
+	}
+	// Each term weighs as the heaviest field it's found in.
+	score := 0.0
+	matched := make(map[string]bool)
+	for _, term := range terms {
+		weight := 0.0
+		for field := range found[term] {
+			matched[field] = true
+			if w := opts.Weight(field); w > weight {
+				weight = w
+			}
+		}
+		score += weight
+	}
+	var fields []string
+	for field := range matched {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	if len(terms) == 0 {
+		return 0, fields
+	}
+	return score / float64(len(terms)), fields
+}
+
+// published returns true if the strip was published in the interval requested.
+func (s SearchOpts) published(x *XKCDStrip) bool {
+	if s.Since.IsZero() && s.Until.IsZero() {
+		return true
+	}
+	date, err := time.Parse("2006-01-02", x.Date)
+	if err != nil {
+		return false
+	}
+	return !date.Before(s.Since) && (s.Until.IsZero() || date.Before(s.Until))
+}
+
+// Search implements Repository.
+func (r *MemoryRepository) Search(queryStr string, opts *SearchOpts) (*SearchResults, error) {
+	if opts == nil {
+		opts = DefaultSearchOpts
+	}
+	// The memory repository understands the terms of the query, and
+	// the exclusions, but not the fields.
+	terms := words(queryStr)
+	var excluded []string
+	if !opts.Raw {
+		parsed, err := parseQuery(queryStr)
+		if err != nil {
+			return nil, err
+		}
+		terms = words(parsed.text())
+		excluded = words(parsed.excluded())
+	}
+	var results SearchResults
+	if len(terms) == 0 {
+		return &results, nil
+	}
+	var hits []*Hit
+	r.mu.RLock()
+	for _, strip := range r.strips {
+		strip := strip
+		score, matched := strip.score(terms, opts)
+		if excludedScore, _ := strip.score(excluded, opts); excludedScore > 0 {
+			continue
+		}
+		if score == 0 || !opts.published(&strip) {
+			continue
+		}
+		hit := &Hit{Strip: copyStrip(&strip), Score: score}
+		if opts.Explain {
+			hit.Matched = matched
+		}
+		hits = append(hits, hit)
+	}
+	r.mu.RUnlock()
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Strip.ID < hits[j].Strip.ID
+	})
+	results.Total = len(hits)
+	if len(hits) > 0 {
+		results.MaxScore = hits[0].Score
+	}
+	for _, hit := range hits {
+		if hit.Score >= opts.MinScore {
+			results.Above++
+		}
+	}
+	size := opts.MaxRecords
+	if size == 0 {
+		size = 10
+	}
+	for i := opts.From; i < len(hits) && i < opts.From+size; i++ {
+		if hits[i].Score >= opts.MinScore {
+			results.Hits = append(results.Hits, hits[i])
+		}
+	}
+	return &results, nil
+}
+
+// Iterate implements Repository. Strips are visited in ascending ID order.
+func (r *MemoryRepository) Iterate(fn func(*XKCDStrip) error) error {
+	r.mu.RLock()
+	ids := make([]int, 0, len(r.strips))
+	for id := range r.strips {
+		ids = append(ids, id)
+	}
+	r.mu.RUnlock()
+	sort.Ints(ids)
+	for _, id := range ids {
+		strip, _ := r.Get(id)
+		if strip == nil {
+			continue
+		}
+		if err := fn(strip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List implements Lister. Strips with the same date are listed in ID order,
+// reversed with the date, and the ones with the same title in ID order.
+func (r *MemoryRepository) List(opts *ListOpts, fn func(*XKCDStrip) error) error {
+	if opts == nil {
+		opts = &ListOpts{}
+	}
+	field, reverse, err := listOrder(opts)
+	if err != nil {
+		return err
+	}
+	var strips []*XKCDStrip
+	r.Iterate(func(strip *XKCDStrip) error {
+		if opts.listed(strip) {
+			strips = append(strips, strip)
+		}
+		return nil
+	})
+	sort.Slice(strips, func(i, j int) bool {
+		a, b := strips[i], strips[j]
+		switch {
+		case field == "date" && a.Date != b.Date:
+			return (a.Date < b.Date) != reverse
+		case field == "title" && a.Title != b.Title:
+			return (a.Title < b.Title) != reverse
+		case field == "title":
+			return a.ID < b.ID
+		}
+		return (a.ID < b.ID) != reverse
+	})
+	for _, strip := range strips {
+		if err := fn(strip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LatestID implements Repository.
+func (r *MemoryRepository) LatestID() (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	latest := 0
+	for id := range r.strips {
+		if id > latest {
+			latest = id
+		}
+	}
+	return latest, nil
+}
+
+// Count implements Repository.
+func (r *MemoryRepository) Count() (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.strips), nil
+}
+
+// Close implements Repository.
+func (r *MemoryRepository) Close() error {
+	return nil
+}
@@ -2,7 +2,9 @@ package database
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/blevesearch/bleve"
@@ -19,6 +21,10 @@ type XKCDStrip struct {
 	Date       string `json:"date"`
 	Img        string `json:"img"`
 	Comment    string `json:"comment"`
+	// User data
+	Tags      []string `json:"tags,omitempty"`
+	Favourite bool     `json:"favourite,omitempty"`
+	Notes     []string `json:"notes,omitempty"`
 }
 
 // NewStrip transforms what we got from the wire into a document
@@ -35,17 +41,27 @@ func NewStrip(w *download.WireXKCD) *XKCDStrip {
 	return &strip
 }
 
-// NewStripFromDb returns an xkcd strip from data recovered from the database.
+// NewStripFromDb returns an xkcd strip from data recovered from the database,
+// or nil if the document is not a valid strip.
 func NewStripFromDb(result *search.DocumentMatch) *XKCDStrip {
 	id, err := strconv.Atoi(result.ID)
 	if err != nil {
 		logger.Errorw("Non-numeric ID found", "error", err)
 		return nil
 	}
+	// Damaged documents can store values of the wrong type.
+	invalid := func(field string) *XKCDStrip {
+		logger.Errorw("Invalid field found", "id", result.ID, "field", field, "value", result.Fields[field])
+		return nil
+	}
 	strip := XKCDStrip{ID: id}
 	if datetime, ok := result.Fields["date"]; ok {
+		raw, ok := datetime.(string)
+		if !ok {
+			return invalid("date")
+		}
 		// This is funnily stored as a full RFC3339 time string
-		t, err := time.Parse(time.RFC3339, datetime.(string))
+		t, err := time.Parse(time.RFC3339, raw)
 		if err == nil {
 			strip.Date = t.Format("2006-01-02")
 		}
@@ -53,20 +69,126 @@ func NewStripFromDb(result *search.DocumentMatch) *XKCDStrip {
 	// This is verbose for code readability. Resist the temptation
 	// of making this code shorter by 2 lines.
 	if title, ok := result.Fields["title"]; ok {
-		strip.Title = title.(string)
+		if strip.Title, ok = title.(string); !ok {
+			return invalid("title")
+		}
 	}
 	if comment, ok := result.Fields["comment"]; ok {
-		strip.Comment = comment.(string)
+		if strip.Comment, ok = comment.(string); !ok {
+			return invalid("comment")
+		}
 	}
 	if img, ok := result.Fields["img"]; ok {
-		strip.Img = img.(string)
+		if strip.Img, ok = img.(string); !ok {
+			return invalid("img")
+		}
 	}
 	if transcript, ok := result.Fields["transcript"]; ok {
-		strip.Transcript = transcript.(string)
+		if strip.Transcript, ok = transcript.(string); !ok {
+			return invalid("transcript")
+		}
+	}
+	// Bleve returns a single value instead of a list of one element.
+	var ok bool
+	if strip.Tags, ok = stringList(result.Fields["tags"]); !ok {
+		return invalid("tags")
+	}
+	if strip.Notes, ok = stringList(result.Fields["notes"]); !ok {
+		return invalid("notes")
+	}
+	if favourite, ok := result.Fields["favourite"]; ok {
+		if strip.Favourite, ok = favourite.(bool); !ok {
+			return invalid("favourite")
+		}
 	}
 	return &strip
 }
 
+// stringList returns the strings of a stored field, which bleve returns as
+// a single value when there is only one. It returns false if the field has
+// values that are not strings.
+func stringList(value interface{}) ([]string, bool) {
+	switch value := value.(type) {
+	case nil:
+		return nil, true
+	case string:
+		return []string{value}, true
+	case []interface{}:
+		var list []string
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, s)
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+// KeepUserData copies the data added by the user from a previous version of the strip.
+func (x *XKCDStrip) KeepUserData(old *XKCDStrip) {
+	if old == nil {
+		return
+	}
+	x.Tags = old.Tags
+	x.Favourite = old.Favourite
+	x.Notes = old.Notes
+}
+
+// normalizeTag returns the canonical form of a tag.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// AddTags adds tags to the strip, ignoring the ones it already has.
+func (x *XKCDStrip) AddTags(tags ...string) {
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag != "" && !x.HasTag(tag) {
+			x.Tags = append(x.Tags, tag)
+		}
+	}
+	sort.Strings(x.Tags)
+}
+
+// RemoveTags removes tags from the strip.
+func (x *XKCDStrip) RemoveTags(tags ...string) {
+	var kept []string
+	for _, existing := range x.Tags {
+		remove := false
+		for _, tag := range tags {
+			if normalizeTag(tag) == existing {
+				remove = true
+			}
+		}
+		if !remove {
+			kept = append(kept, existing)
+		}
+	}
+	x.Tags = kept
+}
+
+// HasTag returns true if the strip is tagged with tag.
+func (x *XKCDStrip) HasTag(tag string) bool {
+	tag = normalizeTag(tag)
+	for _, existing := range x.Tags {
+		if existing == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// AddNote appends a personal note to the strip, ignoring empty ones.
+func (x *XKCDStrip) AddNote(note string) {
+	note = strings.TrimSpace(note)
+	if note != "" {
+		x.Notes = append(x.Notes, note)
+	}
+}
+
 // BleveType implements the BleveClassifier interface
 func (x *XKCDStrip) BleveType() string {
 	return "xkcd"
@@ -81,6 +203,11 @@ func (x *XKCDStrip) Index(idx bleve.Index) error {
 	return err
 }
 
+// AddTo adds the indexing of this resource to a batch of operations on a bleve index.
+func (x *XKCDStrip) AddTo(b *bleve.Batch) error {
+	return b.Index(strconv.Itoa(x.ID), x)
+}
+
 // Summary offers a formatted output.
 func (x XKCDStrip) Summary() string {
 	return fmt.Sprintf("XKCD %d (%s): %s\n\tstrip: %s\n", x.ID, x.Date, x.Title, x.Img)
@@ -91,24 +218,73 @@ func (x XKCDStrip) URL() string {
 	return fmt.Sprintf("https://xkcd.com/%d", x.ID)
 }
 
-var allFields = []string{"title", "id", "img", "comment", "transcript", "date"}
+// StripFields are the names of the values of a strip shown to the user.
+var StripFields = []string{"id", "title", "date", "url", "img", "alt", "transcript", "tags", "favourite", "notes"}
+
+// Field returns one of the values of the strip listed in StripFields, as text.
+// Tags are separated by commas, and notes by newlines.
+func (x XKCDStrip) Field(name string) (string, error) {
+	switch name {
+	case "id":
+		return strconv.Itoa(x.ID), nil
+	case "title":
+		return x.Title, nil
+	case "date":
+		return x.Date, nil
+	case "url":
+		return x.URL(), nil
+	case "img":
+		return x.Img, nil
+	case "alt":
+		return x.Comment, nil
+	case "transcript":
+		return x.Transcript, nil
+	case "tags":
+		return strings.Join(x.Tags, ","), nil
+	case "favourite":
+		return strconv.FormatBool(x.Favourite), nil
+	case "notes":
+		return strings.Join(x.Notes, "\n"), nil
+	}
+	return "", fmt.Errorf("unknown field %q: use one of %s", name, strings.Join(StripFields, ", "))
+}
+
+var allFields = []string{"title", "id", "img", "comment", "transcript", "date", "tags", "favourite", "notes"}
 
-// DocMapping returns a bleve document mapping suitable to store this object
-// and attaches it to a main index mapping.
-func DocMapping() *mapping.DocumentMapping {
+// DocMapping returns a bleve document mapping suitable to store this object,
+// analyzing the text fields as specified in the configuration.
+func DocMapping(a *AnalysisConfig) *mapping.DocumentMapping {
 	docmap := bleve.NewDocumentMapping()
 	title := bleve.NewTextFieldMapping()
-	docmap.AddFieldMappingsAt("title", title)
 	title.Store = true
+	title.Analyzer = a.analyzerFor("title")
+	// The whole title is also indexed as a single term, to allow exact matches.
+	titleExact := bleve.NewTextFieldMapping()
+	titleExact.Name = "title_exact"
+	titleExact.Analyzer = a.analyzerFor("title_exact")
+	titleExact.IncludeInAll = false
+	docmap.AddFieldMappingsAt("title", title, titleExact)
 	id := bleve.NewNumericFieldMapping()
 	docmap.AddFieldMappingsAt("id", id)
-	for _, label := range []string{"img", "comment", "transcript"} {
+	img := bleve.NewTextFieldMapping()
+	img.Store = true
+	docmap.AddFieldMappingsAt("img", img)
+	for _, label := range []string{"comment", "transcript", "notes"} {
 		fm := bleve.NewTextFieldMapping()
 		fm.Store = true
+		fm.Analyzer = a.analyzerFor(label)
 		docmap.AddFieldMappingsAt(label, fm)
 	}
 	datemap := bleve.NewDateTimeFieldMapping()
 	datemap.Store = true
 	docmap.AddFieldMappingsAt("date", datemap)
+	tags := bleve.NewTextFieldMapping()
+	tags.Store = true
+	tags.Analyzer = keywordAnalyzer
+	docmap.AddFieldMappingsAt("tags", tags)
+	favourite := bleve.NewBooleanFieldMapping()
+	favourite.Store = true
+	favourite.IncludeInAll = false
+	docmap.AddFieldMappingsAt("favourite", favourite)
 	return docmap
 }
@@ -46,3 +46,50 @@ func TestSummary(t *testing.T) {
 `
 	assert.Equal(t, expectedSummary, strip.Summary())
 }
+
+func TestTags(t *testing.T) {
+	strip := XKCDStrip{ID: 327}
+	strip.AddTags("SQL", "security", "sql", " ")
+	assert.Equal(t, []string{"security", "sql"}, strip.Tags)
+	assert.True(t, strip.HasTag("Security"))
+	strip.RemoveTags("security", "missing")
+	assert.Equal(t, []string{"sql"}, strip.Tags)
+	strip.Favourite = true
+	downloaded := XKCDStrip{ID: 327, Title: "Exploits of a Mom"}
+	downloaded.KeepUserData(&strip)
+	assert.Equal(t, []string{"sql"}, downloaded.Tags)
+	assert.True(t, downloaded.Favourite)
+}
+
+func TestNotes(t *testing.T) {
+	strip := XKCDStrip{ID: 1171}
+	strip.AddNote("  perfect for regex discussions ")
+	strip.AddNote(" ")
+	assert.Equal(t, []string{"perfect for regex discussions"}, strip.Notes)
+	downloaded := XKCDStrip{ID: 1171, Title: "Perl Problems"}
+	downloaded.KeepUserData(&strip)
+	assert.Equal(t, strip.Notes, downloaded.Notes)
+}
+
+func TestField(t *testing.T) {
+	strip := XKCDStrip{ID: 327, Title: "Exploits of a Mom", Comment: "Her daughter is named Help I'm trapped in a driver's license factory.",
+		Tags: []string{"security", "sql"}, Notes: []string{"first", "second"}}
+	for name, expected := range map[string]string{
+		"id":        "327",
+		"url":       "https://xkcd.com/327",
+		"alt":       strip.Comment,
+		"tags":      "security,sql",
+		"favourite": "false",
+		"notes":     "first\nsecond",
+	} {
+		value, err := strip.Field(name)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, value, name)
+	}
+	for _, name := range StripFields {
+		_, err := strip.Field(name)
+		assert.Nil(t, err, name)
+	}
+	_, err := strip.Field("comment")
+	assert.NotNil(t, err)
+}
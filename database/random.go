@@ -0,0 +1,61 @@
+package database
+
+import "math/rand"
+
+// RandomOpts restricts the strips Random picks from.
+type RandomOpts struct {
+	// Query, if not empty, only allows the strips matching it above
+	// Search.MinScore.
+	Query string
+	// Tag, if not empty, only allows the strips with this tag.
+	Tag string
+	// Search are the options of the search; only the dates and MinScore
+	// are used without a query.
+	Search SearchOpts
+}
+
+// Random picks a strip uniformly at random among the ones allowed by opts,
+// using rng as the source of randomness. It returns nil if no strip is allowed.
+func Random(repo Repository, opts *RandomOpts, rng *rand.Rand) (*XKCDStrip, error) {
+	if opts == nil {
+		opts = &RandomOpts{}
+	}
+	if opts.Query == "" {
+		// Strips are visited in order, so the same seed picks the same strip.
+		var candidates []*XKCDStrip
+		err := repo.Iterate(func(strip *XKCDStrip) error {
+			if opts.Search.published(strip) && (opts.Tag == "" || strip.HasTag(opts.Tag)) {
+				candidates = append(candidates, strip)
+			}
+			return nil
+		})
+		if err != nil || len(candidates) == 0 {
+			return nil, err
+		}
+		return candidates[rng.Intn(len(candidates))], nil
+	}
+	search := opts.Search
+	search.From = 0
+	search.MaxRecords = 1
+	search.Highlight = ""
+	results, err := repo.Search(opts.Query, &search)
+	if err != nil || results.Above == 0 {
+		return nil, err
+	}
+	// The results above the minimum score come first: get all of them.
+	search.MaxRecords = results.Above
+	results, err = repo.Search(opts.Query, &search)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*XKCDStrip
+	for _, hit := range results.Hits {
+		if opts.Tag == "" || hit.Strip.HasTag(opts.Tag) {
+			candidates = append(candidates, hit.Strip)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	return candidates[rng.Intn(len(candidates))], nil
+}
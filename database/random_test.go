@@ -0,0 +1,51 @@
+package database
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandom(t *testing.T) {
+	repo := NewMemoryRepository()
+	for i := 1; i <= 20; i++ {
+		strip := &XKCDStrip{ID: i, Title: "A strip", Date: "2010-01-01"}
+		if i%2 == 0 {
+			strip.Title = "An even strip"
+			strip.AddTags("even")
+		}
+		if i > 10 {
+			strip.Date = "2012-06-01"
+		}
+		repo.Put(strip)
+	}
+	pick := func(opts *RandomOpts, seed int64) int {
+		strip, err := Random(repo, opts, rand.New(rand.NewSource(seed)))
+		assert.Nil(t, err)
+		if strip == nil {
+			return 0
+		}
+		return strip.ID
+	}
+	// The same seed always picks the same strip.
+	assert.Equal(t, pick(nil, 42), pick(nil, 42))
+	seen := make(map[int]bool)
+	for seed := int64(0); seed < 200; seed++ {
+		id := pick(&RandomOpts{Tag: "Even"}, seed)
+		assert.Equal(t, 0, id%2)
+		seen[id] = true
+	}
+	assert.Len(t, seen, 10)
+
+	opts := &RandomOpts{Query: "even"}
+	opts.Search.Since, opts.Search.Until, _ = ParseDateRange("2012", time.Now())
+	for seed := int64(0); seed < 20; seed++ {
+		id := pick(opts, seed)
+		assert.True(t, id > 10 && id%2 == 0, id)
+	}
+	assert.Equal(t, 0, pick(&RandomOpts{Query: "missing"}, 1))
+	assert.Equal(t, 0, pick(&RandomOpts{Query: "even", Tag: "odd"}, 1))
+	assert.Equal(t, 0, pick(&RandomOpts{Tag: "missing"}, 1))
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// relatedFields are the fields we take the terms describing a strip from.
+var relatedFields = []string{"title", "comment", "transcript"}
+
+// DefaultRelatedTerms is the number of terms used to find related strips.
+const DefaultRelatedTerms = 25
+
+// RelatedTerm is a term characterizing a strip, with its weight.
+type RelatedTerm struct {
+	Field  string
+	Term   string
+	Weight float64
+}
+
+// RelatedTerms returns the n terms best characterizing a strip, ranked by
+// TF-IDF: how often they appear in the strip, and how rare they are in the index.
+// Terms that don't appear in any other strip are skipped, as they can't
+// match anything.
+func RelatedTerms(idx bleve.Index, strip *XKCDStrip, n int) ([]RelatedTerm, error) {
+	count, err := idx.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	texts := map[string]string{
+		"title":      strip.Title,
+		"comment":    strip.Comment,
+		"transcript": strip.Transcript,
+	}
+	m := idx.Mapping()
+	var terms []RelatedTerm
+	for _, field := range relatedFields {
+		analyzer := m.AnalyzerNamed(m.AnalyzerNameForPath(field))
+		if analyzer == nil {
+			return nil, fmt.Errorf("no analyzer found for field %s", field)
+		}
+		frequencies := make(map[string]int)
+		for _, token := range analyzer.Analyze([]byte(texts[field])) {
+			frequencies[string(token.Term)]++
+		}
+		if len(frequencies) == 0 {
+			continue
+		}
+		documents, err := docFrequencies(idx, field)
+		if err != nil {
+			return nil, err
+		}
+		for term, tf := range frequencies {
+			df := documents[term]
+			if df < 2 {
+				continue
+			}
+			idf := 1 + math.Log(float64(count)/float64(df+1))
+			terms = append(terms, RelatedTerm{Field: field, Term: term, Weight: float64(tf) * idf})
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Weight != terms[j].Weight {
+			return terms[i].Weight > terms[j].Weight
+		}
+		if terms[i].Field != terms[j].Field {
+			return terms[i].Field < terms[j].Field
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms, nil
+}
+
+// docFrequencies returns the number of documents each term of a field appears in.
+func docFrequencies(idx bleve.Index, field string) (map[string]uint64, error) {
+	dict, err := idx.FieldDict(field)
+	if err != nil {
+		return nil, err
+	}
+	defer dict.Close()
+	frequencies := make(map[string]uint64)
+	for {
+		entry, err := dict.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return frequencies, nil
+		}
+		frequencies[entry.Term] = entry.Count
+	}
+}
+
+// relatedQuery builds a query matching the strips sharing the given terms
+// with the strip with the given ID, excluding the strip itself.
+func relatedQuery(id int, terms []RelatedTerm) query.Query {
+	should := make([]query.Query, 0, len(terms))
+	for _, term := range terms {
+		q := bleve.NewTermQuery(term.Term)
+		q.SetField(term.Field)
+		q.SetBoost(term.Weight)
+		should = append(should, q)
+	}
+	q := bleve.NewBooleanQuery()
+	q.AddShould(should...)
+	q.AddMustNot(bleve.NewDocIDQuery([]string{strconv.Itoa(id)}))
+	return q
+}
+
+// Related searches the strips most similar to the one with the given ID,
+// using its most characterizing terms.
+func Related(idx bleve.Index, id int, opts *SearchOpts) (*bleve.SearchResult, error) {
+	if opts == nil {
+		opts = DefaultSearchOpts
+	}
+	strip, err := NewBleveRepository(idx).Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if strip == nil {
+		return nil, fmt.Errorf("strip %d is not in the database", id)
+	}
+	terms, err := RelatedTerms(idx, strip, DefaultRelatedTerms)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return &bleve.SearchResult{}, nil
+	}
+	logger.Debugw("Searching related strips", "id", id, "terms", terms)
+	request := bleve.NewSearchRequest(opts.Filter(relatedQuery(id, terms)))
+	opts.Apply(request)
+	return idx.Search(request)
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelated(t *testing.T) {
+	setup()
+	defer teardown()
+	strips := []*XKCDStrip{
+		{ID: 327, Title: "Exploits of a Mom", Comment: "Her daughter is named Help I'm trapped in a driver's license factory.",
+			Transcript: "Did you really name your son Robert'); DROP TABLE Students;-- ? We've lost this year's student records. I hope you've learned to sanitize your database inputs."},
+		{ID: 1253, Title: "Exoplanet Names", Comment: "The database inputs were not sanitized.",
+			Transcript: "The students named the planets; the database of the school was lost."},
+		{ID: 1171, Title: "Perl Problems", Comment: "To generate #1 albums, 'jay --help' recommends the -z flag.",
+			Transcript: "If you're having Perl problems I feel bad for you, son. I got 99 problems, so I used regular expressions."},
+	}
+	for _, strip := range strips {
+		assert.Nil(t, strip.Index(fixturedb))
+	}
+	terms, err := RelatedTerms(fixturedb, strips[0], 5)
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, len(terms), 5)
+	if assert.NotEmpty(t, terms) {
+		// "Mom" and "Exploits" appear only in the strip itself, and are useless.
+		for _, term := range terms {
+			assert.NotEqual(t, "mom", term.Term)
+		}
+		assert.GreaterOrEqual(t, terms[0].Weight, terms[len(terms)-1].Weight)
+	}
+
+	results, err := Related(fixturedb, 327, nil)
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, results.Hits) {
+		assert.Equal(t, "1253", results.Hits[0].ID)
+	}
+	for _, hit := range results.Hits {
+		assert.NotEqual(t, "327", hit.ID)
+	}
+
+	_, err = Related(fixturedb, 1, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+)
+
+// Repository stores the strips and allows searching them, independently
+// of the storage used.
+type Repository interface {
+	// Get returns the strip with the given ID, or nil if it's not stored.
+	Get(id int) (*XKCDStrip, error)
+	// Put stores a strip, replacing any previous version of it.
+	Put(strip *XKCDStrip) error
+	// Delete removes a strip.
+	Delete(id int) error
+	// Search returns the strips matching a query.
+	Search(queryStr string, opts *SearchOpts) (*SearchResults, error)
+	// Iterate calls fn on every strip, stopping at the first error.
+	Iterate(fn func(*XKCDStrip) error) error
+	// LatestID returns the highest ID stored, or 0 if there are no strips.
+	LatestID() (int, error)
+	// Count returns the number of strips stored.
+	Count() (int, error)
+	// Close releases the resources used by the repository.
+	Close() error
+}
+
+// Faceter is implemented by repositories that can describe the distribution
+// of the results of a search.
+type Faceter interface {
+	Facets(queryStr string, opts *SearchOpts) (*Facets, error)
+}
+
+// Lister is implemented by repositories that can list the strips in a
+// given order.
+type Lister interface {
+	List(opts *ListOpts, fn func(*XKCDStrip) error) error
+}
+
+// Relater is implemented by repositories that can find the strips similar
+// to a given one.
+type Relater interface {
+	Related(id int, opts *SearchOpts) (*SearchResults, error)
+}
+
+// Hit is a strip matching a search.
+type Hit struct {
+	Strip     *XKCDStrip
+	Score     float64
+	Fragments []Fragment
+	// Matched lists the fields the query matched in, if SearchOpts.Explain is set.
+	Matched []string
+}
+
+// SearchResults is the page of hits of a search that was requested.
+type SearchResults struct {
+	// Total is the number of strips matching the query.
+	Total int
+	// Above is the number of strips scoring at least SearchOpts.MinScore.
+	Above int
+	// MaxScore is the highest score of all the strips matching the query.
+	MaxScore float64
+	// Hits are the results in the requested page scoring at least SearchOpts.MinScore,
+	// sorted by descending score.
+	Hits []*Hit
+}
+
+// SaveDownloaded stores a freshly downloaded strip, keeping the data the
+// user added to the version already in the repository, if any.
+func SaveDownloaded(repo Repository, strip *XKCDStrip) error {
+	old, err := repo.Get(strip.ID)
+	if err != nil {
+		return err
+	}
+	strip.KeepUserData(old)
+	return repo.Put(strip)
+}
+
+// BleveRepository is a Repository storing the strips in a bleve index.
+type BleveRepository struct {
+	idx bleve.Index
+}
+
+// NewBleveRepository returns a repository based on an open bleve index.
+func NewBleveRepository(idx bleve.Index) *BleveRepository {
+	return &BleveRepository{idx: idx}
+}
+
+// OpenRepository opens the database at path, and returns a repository for it.
+func OpenRepository(path string, opts *OpenOpts) (*BleveRepository, error) {
+	idx, err := OpenWith(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewBleveRepository(idx), nil
+}
+
+// Index returns the underlying bleve index.
+func (r *BleveRepository) Index() bleve.Index {
+	return r.idx
+}
+
+// Get implements Repository.
+func (r *BleveRepository) Get(id int) (*XKCDStrip, error) {
+	search := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{strconv.Itoa(id)}))
+	search.Fields = allFields
+	results, err := r.idx.Search(search)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Hits) == 0 {
+		return nil, nil
+	}
+	strip := NewStripFromDb(results.Hits[0])
+	if strip == nil {
+		return nil, fmt.Errorf("could not decode strip %d", id)
+	}
+	return strip, nil
+}
+
+// Put implements Repository.
+func (r *BleveRepository) Put(strip *XKCDStrip) error {
+	return strip.Index(r.idx)
+}
+
+// Delete implements Repository.
+func (r *BleveRepository) Delete(id int) error {
+	return r.idx.Delete(strconv.Itoa(id))
+}
+
+// Search implements Repository.
+func (r *BleveRepository) Search(queryStr string, opts *SearchOpts) (*SearchResults, error) {
+	if opts == nil {
+		opts = DefaultSearchOpts
+	}
+	result, err := SearchStr(r.idx, queryStr, opts)
+	if err != nil {
+		return nil, err
+	}
+	results := newSearchResults(result, opts.MinScore)
+	if opts.MinScore > 0 {
+		results.Above, err = CountAbove(r.idx, queryStr, opts, opts.MinScore)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// newSearchResults converts the result of a bleve search, skipping the hits
+// scoring less than minScore.
+func newSearchResults(result *bleve.SearchResult, minScore float64) *SearchResults {
+	results := SearchResults{
+		Total:    int(result.Total),
+		Above:    int(result.Total),
+		MaxScore: result.MaxScore,
+	}
+	for _, match := range result.Hits {
+		if match.Score < minScore {
+			continue
+		}
+		strip := NewStripFromDb(match)
+		if strip == nil {
+			continue
+		}
+		results.Hits = append(results.Hits, &Hit{
+			Strip:     strip,
+			Score:     match.Score,
+			Fragments: BestFragments(match),
+			Matched:   matchedFields(match),
+		})
+	}
+	return &results
+}
+
+// matchedFields returns the fields a hit matched in, if their locations were requested.
+func matchedFields(match *search.DocumentMatch) []string {
+	var fields []string
+	for field := range match.Locations {
+		if _, ok := boostFields[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Related implements Relater. The scores of related strips are not
+// comparable to the ones of a search, so SearchOpts.MinScore is ignored.
+func (r *BleveRepository) Related(id int, opts *SearchOpts) (*SearchResults, error) {
+	result, err := Related(r.idx, id, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newSearchResults(result, 0), nil
+}
+
+// Facets implements Faceter.
+func (r *BleveRepository) Facets(queryStr string, opts *SearchOpts) (*Facets, error) {
+	return SearchFacets(r.idx, queryStr, opts)
+}
+
+// List implements Lister.
+func (r *BleveRepository) List(opts *ListOpts, fn func(*XKCDStrip) error) error {
+	return ListStrips(r.idx, opts, fn)
+}
+
+// Iterate implements Repository.
+func (r *BleveRepository) Iterate(fn func(*XKCDStrip) error) error {
+	return forEachStrip(r.idx, fn)
+}
+
+// LatestID implements Repository.
+func (r *BleveRepository) LatestID() (int, error) {
+	allRecords, err := GetAll(r.idx, &SearchOpts{MaxRecords: 1, SortBy: []string{"-id"}})
+	if err != nil {
+		return 0, err
+	}
+	if len(allRecords.Hits) == 0 {
+		return 0, nil
+	}
+	return strconv.Atoi(allRecords.Hits[0].ID)
+}
+
+// Count implements Repository.
+func (r *BleveRepository) Count() (int, error) {
+	count, err := r.idx.DocCount()
+	return int(count), err
+}
+
+// Close implements Repository.
+func (r *BleveRepository) Close() error {
+	return r.idx.Close()
+}
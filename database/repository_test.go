@@ -0,0 +1,140 @@
+package database
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// testRepository runs the same checks on any Repository implementation.
+func testRepository(t *testing.T, repo Repository) {
+	for i := 1; i <= 5; i++ {
+		err := repo.Put(&XKCDStrip{
+			ID:         i * 10,
+			Title:      fmt.Sprintf("strip %d", i),
+			Date:       fmt.Sprintf("200%d-01-01", i),
+			Comment:    "a comment",
+			Transcript: fmt.Sprintf("transcript of the %d strip", i),
+		})
+		assert.Nil(t, err)
+	}
+	count, err := repo.Count()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, count)
+	latest, err := repo.LatestID()
+	assert.Nil(t, err)
+	assert.Equal(t, 50, latest)
+
+	strip, err := repo.Get(30)
+	assert.Nil(t, err)
+	assert.Equal(t, "strip 3", strip.Title)
+	assert.Equal(t, "2003-01-01", strip.Date)
+	strip, err = repo.Get(31)
+	assert.Nil(t, err)
+	assert.Nil(t, strip)
+
+	assert.Nil(t, repo.Delete(50))
+	latest, _ = repo.LatestID()
+	assert.Equal(t, 40, latest)
+
+	var ids []int
+	err = repo.Iterate(func(x *XKCDStrip) error {
+		ids = append(ids, x.ID)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{10, 20, 30, 40}, ids)
+
+	opts := *DefaultSearchOpts
+	opts.MaxRecords = 2
+	opts.From = 1
+	results, err := repo.Search("comment", &opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, results.Total)
+	assert.Equal(t, 4, results.Above)
+	assert.Equal(t, 2, len(results.Hits))
+	opts = *DefaultSearchOpts
+	opts.Since, opts.Until, _ = ParseDateRange("2002", time.Now())
+	results, err = repo.Search("transcript", &opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, results.Total)
+	assert.Equal(t, 20, results.Hits[0].Strip.ID)
+	opts = *DefaultSearchOpts
+	opts.MinScore = results.MaxScore + 1
+	results, err = repo.Search("transcript", &opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, results.Total)
+	assert.Equal(t, 0, results.Above)
+	assert.Equal(t, 0, len(results.Hits))
+
+	// User data is stored, and tags can be searched.
+	strip, _ = repo.Get(40)
+	strip.AddTags("security", "sql")
+	strip.Favourite = true
+	assert.Nil(t, repo.Put(strip))
+	strip, _ = repo.Get(40)
+	assert.Equal(t, []string{"security", "sql"}, strip.Tags)
+	assert.True(t, strip.Favourite)
+	strip.RemoveTags("security")
+	assert.Nil(t, repo.Put(strip))
+	strip, _ = repo.Get(40)
+	assert.Equal(t, []string{"sql"}, strip.Tags)
+
+	// Changing a strip doesn't change the stored one until it's put back.
+	tags := make([]string, 1, 4)
+	tags[0] = "zeta"
+	assert.Nil(t, repo.Put(&XKCDStrip{ID: 60, Title: "strip 6", Date: "2006-01-01", Tags: tags, Notes: []string{"a note"}}))
+	tags[0] = "changed"
+	changed, _ := repo.Get(60)
+	changed.AddTags("alpha")
+	changed.Notes[0] = "another note"
+	stored, _ := repo.Get(60)
+	assert.Equal(t, []string{"zeta"}, stored.Tags)
+	assert.Equal(t, []string{"a note"}, stored.Notes)
+	assert.Nil(t, repo.Delete(60))
+	results, err = repo.Search("sql", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, results.Total)
+	assert.Equal(t, 40, results.Hits[0].Strip.ID)
+
+	// Notes are searchable too.
+	strip.AddNote("perfect for regex discussions")
+	assert.Nil(t, repo.Put(strip))
+	results, err = repo.Search("discussions", nil)
+	assert.Nil(t, err)
+	if assert.Len(t, results.Hits, 1) {
+		assert.Equal(t, 40, results.Hits[0].Strip.ID)
+		assert.Equal(t, []string{"perfect for regex discussions"}, results.Hits[0].Strip.Notes)
+	}
+
+	// Terms can be excluded, and the syntax is checked.
+	results, err = repo.Search("sql -regex", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, results.Total)
+	_, err = repo.Search(`sql "regex`, nil)
+	assert.IsType(t, QuerySyntaxError{}, err)
+}
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, NewMemoryRepository())
+}
+
+func TestBleveRepository(t *testing.T) {
+	l, _ := zap.NewDevelopment()
+	logger = l.Sugar()
+	tempdir, err := ioutil.TempDir("", "xkcli-test")
+	if err != nil {
+		t.Fatalf("Unable to create the temporary directory")
+	}
+	defer os.RemoveAll(tempdir)
+	repo, err := OpenRepository(path.Join(tempdir, "xkcli.bleve"), nil)
+	assert.Nil(t, err)
+	defer repo.Close()
+	testRepository(t, repo)
+}
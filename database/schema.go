@@ -0,0 +1,278 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// schemaVersionKey is the internal key under which we store the schema version
+// of the index.
+var schemaVersionKey = []byte("xkcli:schemaVersion")
+
+// analysisKey is the internal key under which we store the fingerprint of the
+// analysis configuration the index was built with.
+var analysisKey = []byte("xkcli:analysis")
+
+// Migration describes the changes needed to bring an index to a schema version.
+type Migration struct {
+	// Version is the schema version of the index once the migration is applied.
+	Version int
+	// Description is a short, human-readable explanation of the change.
+	Description string
+	// Rebuild is true if the mapping changed, and all documents need
+	// to be reindexed into a new index.
+	Rebuild bool
+	// Transform, if not nil, is applied to every document during a rebuild.
+	Transform func(*XKCDStrip)
+}
+
+// ErrOutdatedSchema is returned when an index needs to be migrated but we
+// are not allowed to write to it.
+type ErrOutdatedSchema struct {
+	Path    string
+	Found   int
+	Current int
+}
+
+func (e ErrOutdatedSchema) Error() string {
+	return fmt.Sprintf(
+		"the index at %s has schema version %d, but version %d is needed: run `xkcli refresh` to migrate it",
+		e.Path, e.Found, e.Current,
+	)
+}
+
+// The registry of migrations, sorted by version.
+var migrations = []Migration{
+	{Version: 1, Description: "Record the schema version in the index"},
+	{Version: 2, Description: "Language-aware analyzers and synonyms", Rebuild: true},
+	{Version: 3, Description: "User tags and favourites", Rebuild: true},
+	{Version: 4, Description: "Personal notes", Rebuild: true},
+}
+
+// RegisterMigration adds a migration to the registry.
+func RegisterMigration(m Migration) {
+	migrations = append(migrations, m)
+	sort.SliceStable(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+}
+
+// SchemaVersion returns the schema version new indexes are created with.
+func SchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// GetSchemaVersion returns the schema version stored in the index.
+// Indexes created before we started versioning the schema are at version 0.
+func GetSchemaVersion(idx bleve.Index) (int, error) {
+	raw, err := idx.GetInternal(schemaVersionKey)
+	if err != nil {
+		return 0, err
+	}
+	if raw == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(string(raw))
+}
+
+// stampIndex records the current schema version and analysis configuration in the index.
+func stampIndex(idx bleve.Index, a *AnalysisConfig) error {
+	if err := idx.SetInternal(schemaVersionKey, []byte(strconv.Itoa(SchemaVersion()))); err != nil {
+		return err
+	}
+	return idx.SetInternal(analysisKey, []byte(a.fingerprint()))
+}
+
+// analysisChanged returns true if the index was built with a different analysis configuration.
+func analysisChanged(idx bleve.Index, a *AnalysisConfig) (bool, error) {
+	raw, err := idx.GetInternal(analysisKey)
+	if err != nil {
+		return false, err
+	}
+	return string(raw) != a.fingerprint(), nil
+}
+
+// pendingMigrations returns the migrations that need to be applied to an
+// index at the given version, and if any of them requires a rebuild.
+func pendingMigrations(version int) ([]Migration, bool) {
+	var pending []Migration
+	rebuild := false
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+			rebuild = rebuild || m.Rebuild
+		}
+	}
+	return pending, rebuild
+}
+
+// migrate brings the index at path to the current schema version. It returns the
+// index to use from now on, which might be different from the one passed in.
+func migrate(idx bleve.Index, path string, opts *OpenOpts) (bleve.Index, error) {
+	version, err := GetSchemaVersion(idx)
+	if err != nil {
+		return idx, err
+	}
+	changed, err := analysisChanged(idx, opts.Analysis)
+	if err != nil {
+		return idx, err
+	}
+	pending, rebuild := pendingMigrations(version)
+	if len(pending) == 0 && !changed {
+		return idx, nil
+	}
+	for _, m := range pending {
+		logger.Infow("Applying migration", "version", m.Version, "description", m.Description)
+	}
+	// Indexes without a version have no analysis fingerprint either.
+	if changed && version > 0 {
+		logger.Infow("The analysis configuration changed, rebuilding the index", "path", path)
+		rebuild = true
+	}
+	if rebuild {
+		idx, err = rebuildIndex(idx, path, pending, opts)
+		if err != nil {
+			return idx, err
+		}
+	}
+	return idx, stampIndex(idx, opts.Analysis)
+}
+
+// Rebuild copies all the stored documents of the index at path into a new index
+// with the current mapping, even if the schema is up to date. It returns the
+// index to use from now on.
+func Rebuild(idx bleve.Index, path string, opts *OpenOpts) (bleve.Index, error) {
+	if opts == nil {
+		opts = &OpenOpts{}
+	}
+	if opts.Analysis == nil {
+		opts.Analysis = DefaultAnalysis
+	}
+	idx, err := rebuildIndex(idx, path, nil, opts)
+	if err != nil {
+		return idx, err
+	}
+	return idx, stampIndex(idx, opts.Analysis)
+}
+
+// rebuildIndex copies all the stored documents into a new index using the current
+// mapping, and swaps it in place of the old one.
+func rebuildIndex(old bleve.Index, path string, pending []Migration, opts *OpenOpts) (bleve.Index, error) {
+	newPath := path + ".migrating"
+	// Remove leftovers from an interrupted migration.
+	if err := os.RemoveAll(newPath); err != nil {
+		return old, err
+	}
+	m, err := NewIndexMapping(opts.Analysis)
+	if err != nil {
+		return old, err
+	}
+	fresh, err := bleve.New(newPath, m)
+	if err != nil {
+		return old, err
+	}
+	total, err := old.DocCount()
+	if err != nil {
+		fresh.Close()
+		return old, err
+	}
+	done := 0
+	err = forEachStrip(old, func(strip *XKCDStrip) error {
+		for _, m := range pending {
+			if m.Transform != nil {
+				m.Transform(strip)
+			}
+		}
+		if err := strip.Index(fresh); err != nil {
+			return err
+		}
+		done++
+		if opts.Progress != nil {
+			opts.Progress(done, int(total))
+		}
+		return nil
+	})
+	if err != nil {
+		fresh.Close()
+		os.RemoveAll(newPath)
+		return old, err
+	}
+	logger.Infow("Rebuilt the index", "path", path, "documents", done)
+	fresh.Close()
+	old.Close()
+	if err := swapIndex(newPath, path); err != nil {
+		return nil, err
+	}
+	return bleve.Open(path)
+}
+
+// swapIndex replaces the index at path, if any, with the one at newPath.
+func swapIndex(newPath, path string) error {
+	// Where the system allows it, exchange the two indexes in one step, so
+	// that there is always an index at path.
+	if err := exchange(newPath, path); err == nil {
+		return os.RemoveAll(newPath)
+	}
+	oldPath := path + ".old"
+	if err := os.RemoveAll(oldPath); err != nil {
+		return err
+	}
+	err := os.Rename(path, oldPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(newPath, path); err != nil {
+		// Try to put the old index back in place.
+		if rerr := os.Rename(oldPath, path); rerr != nil && !os.IsNotExist(rerr) {
+			return fmt.Errorf("%s; the previous index was left at %s: %s", err, oldPath, rerr)
+		}
+		return err
+	}
+	return os.RemoveAll(oldPath)
+}
+
+// forEachStrip calls fn on every strip in the index in ID order, paging through the results.
+func forEachStrip(idx bleve.Index, fn func(*XKCDStrip) error) error {
+	return forEachHit(idx, func(hit *search.DocumentMatch) error {
+		strip := NewStripFromDb(hit)
+		if strip == nil {
+			return nil
+		}
+		return fn(strip)
+	})
+}
+
+// forEachHit calls fn on every document in the index in ID order, paging through the results.
+func forEachHit(idx bleve.Index, fn func(*search.DocumentMatch) error) error {
+	return forEachMatch(idx, bleve.NewMatchAllQuery(), []string{"id", "_id"}, fn)
+}
+
+// forEachMatch calls fn on every document matching q in the given order,
+// paging through the results.
+func forEachMatch(idx bleve.Index, q query.Query, sortBy []string, fn func(*search.DocumentMatch) error) error {
+	pageSize := 500
+	for from := 0; ; from += pageSize {
+		request := bleve.NewSearchRequestOptions(q, pageSize, from, false)
+		request.Fields = allFields
+		request.SortBy(sortBy)
+		results, err := idx.Search(request)
+		if err != nil {
+			return err
+		}
+		for _, hit := range results.Hits {
+			if err := fn(hit); err != nil {
+				return err
+			}
+		}
+		if len(results.Hits) < pageSize {
+			return nil
+		}
+	}
+}
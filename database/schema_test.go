@@ -0,0 +1,91 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/blevesearch/bleve"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func schema_setup(t *testing.T) (string, func()) {
+	l, _ := zap.NewDevelopment()
+	logger = l.Sugar()
+	tempdir, err := ioutil.TempDir("", "xkcli-test")
+	if err != nil {
+		t.Fatalf("Unable to create the temporary directory")
+	}
+	saved := migrations
+	return tempdir, func() {
+		migrations = saved
+		os.RemoveAll(tempdir)
+	}
+}
+
+// A new database is created at the current schema version.
+func TestOpenStampsVersion(t *testing.T) {
+	tempdir, teardown := schema_setup(t)
+	defer teardown()
+	db, err := Open(path.Join(tempdir, "xkcli.bleve"))
+	assert.Nil(t, err)
+	defer db.Close()
+	version, err := GetSchemaVersion(db)
+	assert.Nil(t, err)
+	assert.Equal(t, SchemaVersion(), version)
+}
+
+// Databases without a version get migrated without losing documents.
+func TestOpenMigratesLegacy(t *testing.T) {
+	tempdir, teardown := schema_setup(t)
+	defer teardown()
+	dbPath := path.Join(tempdir, "xkcli.bleve")
+	m, _ := NewIndexMapping(nil)
+	legacy, err := bleve.New(dbPath, m)
+	assert.Nil(t, err)
+	(&XKCDStrip{ID: 1, Title: "Barrel - Part 1", Date: "2006-01-01"}).Index(legacy)
+	legacy.Close()
+
+	var calls int
+	RegisterMigration(Migration{
+		Version:     SchemaVersion() + 1,
+		Description: "test rebuild",
+		Rebuild:     true,
+		Transform:   func(x *XKCDStrip) { x.Title = "migrated" },
+	})
+	db, err := OpenWith(dbPath, &OpenOpts{Progress: func(done, total int) { calls++ }})
+	assert.Nil(t, err)
+	defer db.Close()
+	version, _ := GetSchemaVersion(db)
+	assert.Equal(t, SchemaVersion(), version)
+	assert.Equal(t, 1, calls)
+	results, _ := GetAll(db, &SearchOpts{Fields: allFields})
+	assert.Equal(t, 1, int(results.Total))
+	strip := NewStripFromDb(results.Hits[0])
+	assert.Equal(t, "migrated", strip.Title)
+	assert.Equal(t, "2006-01-01", strip.Date)
+	_, err = os.Stat(dbPath + ".old")
+	assert.True(t, os.IsNotExist(err))
+}
+
+// Read-only opens refuse databases that need to be rebuilt.
+func TestOpenReadOnlyOutdated(t *testing.T) {
+	tempdir, teardown := schema_setup(t)
+	defer teardown()
+	dbPath := path.Join(tempdir, "xkcli.bleve")
+	db, err := Open(dbPath)
+	assert.Nil(t, err)
+	db.Close()
+	// An up-to-date database can be opened.
+	db, err = OpenWith(dbPath, &OpenOpts{ReadOnly: true})
+	assert.Nil(t, err)
+	db.Close()
+	RegisterMigration(Migration{Version: SchemaVersion() + 1, Rebuild: true})
+	_, err = OpenWith(dbPath, &OpenOpts{ReadOnly: true})
+	assert.IsType(t, ErrOutdatedSchema{}, err)
+	// A missing database isn't created.
+	_, err = OpenWith(path.Join(tempdir, "missing"), &OpenOpts{ReadOnly: true})
+	assert.Error(t, err)
+}
@@ -0,0 +1,11 @@
+//go:build linux
+// +build linux
+
+package database
+
+import "golang.org/x/sys/unix"
+
+// exchange atomically swaps the files at the two paths.
+func exchange(a, b string) error {
+	return unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+}
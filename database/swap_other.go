@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package database
+
+import "errors"
+
+// exchange atomically swaps the files at the two paths; this system can't.
+func exchange(a, b string) error {
+	return errors.New("exchanging files is not supported")
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// queryFields maps the field names accepted in a query to the indexed fields.
+var queryFields = map[string]string{
+	"title":       "title",
+	"title_exact": "title_exact",
+	"alt":         "comment",
+	"comment":     "comment",
+	"transcript":  "transcript",
+	"notes":       "notes",
+	"tags":        "tags",
+}
+
+// How a term of the query affects the results.
+const (
+	termShould = iota
+	termMust
+	termMustNot
+)
+
+// queryTerm is a term of a query, like -title:"a phrase".
+type queryTerm struct {
+	occur  int
+	field  string
+	text   string
+	phrase bool
+	// id is set for terms like #327, matching a single strip.
+	id string
+}
+
+// QuerySyntaxError reports an error in a query, and where it was found.
+type QuerySyntaxError struct {
+	Query string
+	// Pos is the position of the error, in bytes.
+	Pos int
+	Msg string
+}
+
+func (e QuerySyntaxError) Error() string {
+	return fmt.Sprintf("invalid query: %s\n  %s\n  %s^", e.Msg, e.Query, strings.Repeat(" ", e.Pos))
+}
+
+// parsedQuery is a query in our simple syntax.
+type parsedQuery []queryTerm
+
+// parseQuery parses a query written in the simple syntax of xkcli, where:
+//   - words and "quoted phrases" are searched in all the fields;
+//   - field:word and field:"a phrase" are searched in title, alt, transcript,
+//     notes, tags or title_exact only;
+//   - a leading + requires a term to match, a leading - excludes the strips it matches;
+//   - #327 matches the strip with that ID.
+func parseQuery(queryStr string) (parsedQuery, error) {
+	var terms parsedQuery
+	fail := func(pos int, format string, args ...interface{}) (parsedQuery, error) {
+		return nil, QuerySyntaxError{Query: queryStr, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+	}
+	for i := 0; i < len(queryStr); {
+		if queryStr[i] == ' ' || queryStr[i] == '\t' {
+			i++
+			continue
+		}
+		start := i
+		term := queryTerm{occur: termShould}
+		switch queryStr[i] {
+		case '+':
+			term.occur = termMust
+			i++
+		case '-':
+			term.occur = termMustNot
+			i++
+		}
+		// A field name is a run of letters and underscores followed by a colon.
+		j := i
+		for j < len(queryStr) && (unicode.IsLetter(rune(queryStr[j])) || queryStr[j] == '_') {
+			j++
+		}
+		if j > i && j < len(queryStr) && queryStr[j] == ':' {
+			name := strings.ToLower(queryStr[i:j])
+			field, ok := queryFields[name]
+			if !ok {
+				return fail(i, "unknown field %q: use one of %s", name, strings.Join(queryFieldNames(), ", "))
+			}
+			term.field = field
+			i = j + 1
+		}
+		switch {
+		case i < len(queryStr) && queryStr[i] == '"':
+			end := strings.IndexByte(queryStr[i+1:], '"')
+			if end < 0 {
+				return fail(i, "unterminated quote")
+			}
+			term.text = queryStr[i+1 : i+1+end]
+			term.phrase = true
+			i += end + 2
+		default:
+			j = i
+			for j < len(queryStr) && queryStr[j] != ' ' && queryStr[j] != '\t' {
+				j++
+			}
+			term.text = queryStr[i:j]
+			i = j
+		}
+		if strings.TrimSpace(term.text) == "" {
+			return fail(start, "nothing to search after %q", queryStr[start:i])
+		}
+		if term.field == "" && !term.phrase && strings.HasPrefix(term.text, "#") {
+			id, err := strconv.Atoi(term.text[1:])
+			if err != nil || id < 1 {
+				return fail(start, "%q is not a valid strip number", term.text)
+			}
+			term.id = strconv.Itoa(id)
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 0 {
+		return fail(0, "the query is empty")
+	}
+	return terms, nil
+}
+
+// queryFieldNames returns the field names accepted in a query, in order.
+func queryFieldNames() []string {
+	names := make([]string, 0, len(queryFields))
+	for name := range queryFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// query returns the bleve query corresponding to the parsed one.
+func (p parsedQuery) query() query.Query {
+	return p.build(queryTerm.query)
+}
+
+// fuzzyQuery is like query, but tolerates typos in the terms the results
+// should match, keeping their fields and whether they are required. The
+// excluded terms and the strip numbers are still matched exactly.
+func (p parsedQuery) fuzzyQuery(fuzziness int) query.Query {
+	return p.build(func(t queryTerm) query.Query {
+		if t.occur == termMustNot {
+			return t.query()
+		}
+		return t.fuzzyQuery(fuzziness)
+	})
+}
+
+// build combines the queries returned by termQuery for each term.
+func (p parsedQuery) build(termQuery func(queryTerm) query.Query) query.Query {
+	q := bleve.NewBooleanQuery()
+	for _, term := range p {
+		switch term.occur {
+		case termMust:
+			q.AddMust(termQuery(term))
+		case termMustNot:
+			q.AddMustNot(termQuery(term))
+		default:
+			q.AddShould(termQuery(term))
+		}
+	}
+	return q
+}
+
+// query returns the bleve query matching a term.
+func (t queryTerm) query() query.Query {
+	switch {
+	case t.id != "":
+		return bleve.NewDocIDQuery([]string{t.id})
+	case t.phrase:
+		phrase := bleve.NewMatchPhraseQuery(t.text)
+		phrase.SetField(t.field)
+		return phrase
+	default:
+		match := bleve.NewMatchQuery(t.text)
+		match.SetField(t.field)
+		return match
+	}
+}
+
+// fuzzyQuery returns the bleve query matching a term with up to fuzziness
+// typos in each word. Phrases match the strips containing all their words,
+// in any order.
+func (t queryTerm) fuzzyQuery(fuzziness int) query.Query {
+	if t.id != "" {
+		return t.query()
+	}
+	match := bleve.NewMatchQuery(t.text)
+	match.SetField(t.field)
+	match.SetFuzziness(fuzziness)
+	if t.phrase {
+		match.SetOperator(query.MatchQueryOperatorAnd)
+	}
+	return match
+}
+
+// text returns the text of the terms the results should match, without
+// the syntax: this is what fuzzy searches and boosts look for.
+func (p parsedQuery) text() string {
+	var texts []string
+	for _, term := range p {
+		if term.occur != termMustNot && term.id == "" {
+			texts = append(texts, term.text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// excluded returns the text of the terms excluded from the results.
+func (p parsedQuery) excluded() string {
+	var texts []string
+	for _, term := range p {
+		if term.occur == termMustNot && term.id == "" {
+			texts = append(texts, term.text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
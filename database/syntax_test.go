@@ -0,0 +1,101 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	parsed, err := parseQuery(`bobby  +tables -title:"star trek" alt:mom #327`)
+	assert.Nil(t, err)
+	assert.Equal(t, parsedQuery{
+		{occur: termShould, text: "bobby"},
+		{occur: termMust, text: "tables"},
+		{occur: termMustNot, field: "title", text: "star trek", phrase: true},
+		{occur: termShould, field: "comment", text: "mom"},
+		{occur: termShould, text: "#327", id: "327"},
+	}, parsed)
+	assert.Equal(t, "bobby tables mom", parsed.text())
+
+	errors := map[string]int{
+		`title:"star trek`: 6,
+		`date:2020`:        0,
+		`bobby -`:          6,
+		`title:`:           0,
+		`#abc`:             0,
+		`   `:              0,
+	}
+	for queryStr, pos := range errors {
+		_, err := parseQuery(queryStr)
+		if assert.IsType(t, QuerySyntaxError{}, err, queryStr) {
+			assert.Equal(t, pos, err.(QuerySyntaxError).Pos, queryStr)
+		}
+	}
+}
+
+func TestSearchStrSyntax(t *testing.T) {
+	setup()
+	defer teardown()
+	strips := []*XKCDStrip{
+		{ID: 327, Title: "Exploits of a Mom", Comment: "Her daughter is named Help I'm trapped in a driver's license factory."},
+		{ID: 1167, Title: "Star Trek into Darkness", Comment: "Star Wars is better anyway."},
+	}
+	for _, strip := range strips {
+		strip.Index(fixturedb)
+	}
+	tests := map[string][]string{
+		"darkness mom":           {"1167", "327"},
+		"title:mom":              {"327"},
+		"alt:wars":               {"1167"},
+		`"into darkness"`:        {"1167"},
+		`"trek star"`:            {},
+		"star -title:trek":       {},
+		"#327":                   {"327"},
+		"+title:star alt:better": {"1167"},
+	}
+	for queryStr, expected := range tests {
+		results, err := SearchStr(fixturedb, queryStr, nil)
+		if !assert.Nil(t, err, queryStr) {
+			continue
+		}
+		ids := []string{}
+		for _, hit := range results.Hits {
+			ids = append(ids, hit.ID)
+		}
+		assert.ElementsMatch(t, expected, ids, queryStr)
+	}
+	// Fuzzy searches tolerate typos, but keep the fields, the required terms
+	// and the exclusions.
+	fuzzy := *DefaultSearchOpts
+	fuzzy.Fuzziness = 1
+	fuzzyTests := map[string][]string{
+		"tek":                  {"1167"},
+		"tek -title:darkness":  {},
+		"title:exploitz":       {"327"},
+		"title:betterr":        {},
+		"+mom +exploitz":       {"327"},
+		"+mom +trekk":          {},
+		"+title:trekk alt:mom": {"1167"},
+		`"darknes starr"`:      {"1167"},
+		`"darknes mom"`:        {},
+		"#327 startrek":        {"327"},
+	}
+	for queryStr, expected := range fuzzyTests {
+		results, err := SearchStr(fixturedb, queryStr, &fuzzy)
+		if !assert.Nil(t, err, queryStr) {
+			continue
+		}
+		ids := []string{}
+		for _, hit := range results.Hits {
+			ids = append(ids, hit.ID)
+		}
+		assert.ElementsMatch(t, expected, ids, queryStr)
+	}
+	// The raw syntax is bleve's, and its errors are reported before searching.
+	results, err := SearchStr(fixturedb, "title:mom^5", &SearchOpts{Raw: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, int(results.Total))
+	_, err = SearchStr(fixturedb, "title:", &SearchOpts{Raw: true})
+	assert.NotNil(t, err)
+}
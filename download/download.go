@@ -2,6 +2,8 @@ package download
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 
 	"go.uber.org/zap"
@@ -61,6 +63,33 @@ func (d *Manager) Get(Id int) *WireXKCD {
 	return wire
 }
 
+// maxImageSize is the largest image we accept to download, in bytes.
+const maxImageSize = 20 << 20
+
+// GetImage fetches the image of a comic strip.
+func (d *Manager) GetImage(url string) ([]byte, error) {
+	d.Bus <- struct{}{}
+	defer func() { <-d.Bus }()
+	logger.Debug("Started downloading ", url)
+	resp, err := d.request(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 399 {
+		return nil, fmt.Errorf("error downloading %s: %s", url, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageSize {
+		return nil, fmt.Errorf("the image %s is too large", url)
+	}
+	logger.Debug("Done downloading ", url)
+	return data, nil
+}
+
 // GetLatestID gets the ID number of the latest XKCD comic strip published.
 func (d Manager) GetLatestID() int {
 	w := d.Get(0)
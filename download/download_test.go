@@ -96,3 +96,15 @@ func TestGetLatestId(t *testing.T) {
 	id := mgr.GetLatestID()
 	assert.Equal(t, id, 1337, "The latest ID doesn't correspond to the server response")
 }
+
+func TestGetImage(t *testing.T) {
+	download_setup()
+	defer download_teardown()
+	handle("/comics/barrel.png", "not really a png", nil)
+	data, err := mgr.GetImage(httpserver.URL + "/comics/barrel.png")
+	assert.Nil(t, err)
+	assert.Equal(t, "not really a png", string(data))
+	assert.Equal(t, 0, len(mgr.Bus), "The channel was not freed")
+	_, err = mgr.GetImage(httpserver.URL + "/comics/missing.png")
+	assert.NotNil(t, err)
+}
@@ -6,7 +6,10 @@ require (
 	github.com/blevesearch/bleve v1.0.7
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.3
 	github.com/spf13/viper v1.6.3
 	github.com/stretchr/testify v1.4.0
 	go.uber.org/zap v1.10.0
+	golang.org/x/sys v0.0.0-20200202164722-d101bd2416d5
+	gopkg.in/yaml.v2 v2.2.4
 )
@@ -0,0 +1,157 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Entry is a search made by the user.
+type Entry struct {
+	Time  time.Time         `json:"time"`
+	Query string            `json:"query"`
+	Flags map[string]string `json:"flags,omitempty"`
+	// TopResult is the ID of the best strip found, or 0 if nothing was found.
+	TopResult int  `json:"topResult,omitempty"`
+	Lucky     bool `json:"lucky,omitempty"`
+}
+
+// Store keeps the history of the searches in a file, one JSON entry per line.
+type Store struct {
+	path string
+}
+
+// NewStore returns a store saving the history to the file at path.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Add appends an entry to the history.
+func (s *Store) Add(e *Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries returns all the entries in the history, from the oldest.
+// Lines that can't be decoded, like one truncated by a crash, are skipped.
+func (s *Store) Entries() ([]*Entry, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []*Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, &e)
+	}
+	return entries, scanner.Err()
+}
+
+// Clear removes all the history.
+func (s *Store) Clear() error {
+	err := os.Remove(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Count is the number of times something appears in the history.
+type Count struct {
+	Key   string
+	Count int
+}
+
+// Stats summarizes the history.
+type Stats struct {
+	Searches int
+	// Terms are the most searched terms.
+	Terms []Count
+	// Strips are the IDs of the strips most often found as the top result.
+	Strips []Count
+}
+
+// Terms splits a query into the lowercase words it contains, ignoring
+// punctuation and the syntax of the query.
+func Terms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ':'
+	})
+	var terms []string
+	for _, word := range words {
+		// Drop the field name from terms like title:foo.
+		if i := strings.LastIndexByte(word, ':'); i >= 0 {
+			word = word[i+1:]
+		}
+		if word != "" {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// ComputeStats returns the n most searched terms and most found strips.
+func ComputeStats(entries []*Entry, n int) *Stats {
+	terms := make(map[string]int)
+	strips := make(map[string]int)
+	for _, e := range entries {
+		// Count every term once per search.
+		seen := make(map[string]bool)
+		for _, term := range Terms(e.Query) {
+			if !seen[term] {
+				seen[term] = true
+				terms[term]++
+			}
+		}
+		if e.TopResult != 0 {
+			strips[strconv.Itoa(e.TopResult)]++
+		}
+	}
+	return &Stats{
+		Searches: len(entries),
+		Terms:    top(terms, n),
+		Strips:   top(strips, n),
+	}
+}
+
+// top returns the n keys with the highest counts.
+func top(counts map[string]int, n int) []Count {
+	result := make([]Count, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, Count{Key: key, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xkcli-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history")
+	store := NewStore(path)
+	entries, err := store.Entries()
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	assert.Nil(t, store.Add(&Entry{Time: now, Query: "bobby tables", TopResult: 327}))
+	assert.Nil(t, store.Add(&Entry{Time: now, Query: "regex", Flags: map[string]string{"year": "2013"}, Lucky: true}))
+	// A truncated line is skipped.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"query": "trunc`)
+	f.Close()
+	entries, err = store.Entries()
+	assert.Nil(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "bobby tables", entries[0].Query)
+		assert.Equal(t, 327, entries[0].TopResult)
+		assert.True(t, now.Equal(entries[0].Time))
+		assert.Equal(t, "2013", entries[1].Flags["year"])
+		assert.True(t, entries[1].Lucky)
+	}
+	assert.Nil(t, store.Clear())
+	entries, err = store.Entries()
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+	assert.Nil(t, store.Clear())
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"bobby", "tables", "mom"}, Terms(`"Bobby Tables" +title:mom`))
+	assert.Empty(t, Terms("  "))
+}
+
+func TestComputeStats(t *testing.T) {
+	entries := []*Entry{
+		{Query: "bobby tables", TopResult: 327},
+		{Query: "bobby bobby", TopResult: 327},
+		{Query: "regex", TopResult: 1171},
+		{Query: "nothing"},
+	}
+	stats := ComputeStats(entries, 2)
+	assert.Equal(t, 4, stats.Searches)
+	assert.Equal(t, []Count{{"bobby", 2}, {"nothing", 1}}, stats.Terms)
+	assert.Equal(t, []Count{{"327", 2}, {"1171", 1}}, stats.Strips)
+}
@@ -0,0 +1,182 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Format is a way of printing the results of a command.
+type Format string
+
+// The formats supported. Text is meant for humans, and is printed by each
+// command on its own; all the others are handled by this package.
+const (
+	Text  Format = "text"
+	JSON  Format = "json"
+	JSONL Format = "jsonl"
+	YAML  Format = "yaml"
+	CSV   Format = "csv"
+	TSV   Format = "tsv"
+)
+
+// Formats lists all the supported formats.
+var Formats = []Format{Text, JSON, JSONL, YAML, CSV, TSV}
+
+// ParseFormat validates the name of a format.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unknown output format %q: use one of %s", name, strings.Join(names, ", "))
+}
+
+// Tabular returns true for the formats printing one record per line.
+func (f Format) Tabular() bool {
+	return f == JSONL || f == CSV || f == TSV
+}
+
+// Encode prints a single document in one of the JSON, JSONL or YAML formats.
+func Encode(w io.Writer, format Format, v interface{}) error {
+	switch format {
+	case JSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case JSONL:
+		return json.NewEncoder(w).Encode(v)
+	case YAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	return fmt.Errorf("can't print a single document as %s", format)
+}
+
+// Record is an element of a list that can also be printed as a row of a table.
+type Record interface {
+	// Values returns the values of the columns of the table.
+	Values() []string
+}
+
+// Writer prints a list of records as they come, so that long lists don't
+// need to be kept in memory. JSON and YAML lists are printed as an array,
+// JSONL as one document per line, and CSV and TSV as a table with a header.
+type Writer struct {
+	w       io.Writer
+	format  Format
+	columns []string
+	csv     *csv.Writer
+	count   int
+}
+
+// NewWriter returns a Writer printing to w; columns is the header of the tables.
+func NewWriter(w io.Writer, format Format, columns []string) (*Writer, error) {
+	writer := &Writer{w: w, format: format, columns: columns}
+	switch format {
+	case CSV:
+		writer.csv = csv.NewWriter(w)
+	case JSON, JSONL, YAML, TSV:
+	default:
+		return nil, fmt.Errorf("can't print a list as %s", format)
+	}
+	return writer, nil
+}
+
+// Write prints a record.
+func (w *Writer) Write(r Record) error {
+	defer func() { w.count++ }()
+	switch w.format {
+	case JSON:
+		separator := ",\n"
+		if w.count == 0 {
+			separator = "[\n"
+		}
+		data, err := json.MarshalIndent(r, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w.w, "%s  %s", separator, data)
+		return err
+	case JSONL:
+		return json.NewEncoder(w.w).Encode(r)
+	case YAML:
+		// A list of one element can be appended to the previous ones.
+		data, err := yaml.Marshal([]Record{r})
+		if err != nil {
+			return err
+		}
+		_, err = w.w.Write(data)
+		return err
+	case CSV:
+		if w.count == 0 {
+			if err := w.csv.Write(w.columns); err != nil {
+				return err
+			}
+		}
+		return w.csv.Write(r.Values())
+	default:
+		if w.count == 0 {
+			if err := w.tsvRow(w.columns); err != nil {
+				return err
+			}
+		}
+		return w.tsvRow(r.Values())
+	}
+}
+
+// tsvRow prints a row of tab-separated values, replacing the characters that
+// would break the table with spaces.
+func (w *Writer) tsvRow(values []string) error {
+	clean := make([]string, len(values))
+	for i, value := range values {
+		clean[i] = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+	}
+	_, err := fmt.Fprintln(w.w, strings.Join(clean, "\t"))
+	return err
+}
+
+// Close terminates the list; an empty list is still a valid document.
+func (w *Writer) Close() error {
+	switch w.format {
+	case JSON:
+		end := "\n]\n"
+		if w.count == 0 {
+			end = "[]\n"
+		}
+		_, err := io.WriteString(w.w, end)
+		return err
+	case YAML:
+		if w.count == 0 {
+			_, err := io.WriteString(w.w, "[]\n")
+			return err
+		}
+	case CSV:
+		if w.count == 0 {
+			w.csv.Write(w.columns)
+		}
+		w.csv.Flush()
+		return w.csv.Error()
+	case TSV:
+		if w.count == 0 {
+			return w.tsvRow(w.columns)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var testStrips = []*database.XKCDStrip{
+	{ID: 327, Title: "Exploits of a Mom", Date: "2007-10-10", Img: "https://imgs.xkcd.com/comics/exploits_of_a_mom.png",
+		Comment: "Her daughter is named Help I'm trapped in a driver's license factory.", Tags: []string{"sql"}},
+	{ID: 1171, Title: "Perl Problems", Date: "2013-02-06", Transcript: "If you're having Perl problems\tI feel bad for you, son.\nI got 99 problems.",
+		Notes: []string{"regex, again"}},
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range Formats {
+		parsed, err := ParseFormat(string(f))
+		assert.Nil(t, err)
+		assert.Equal(t, f, parsed)
+	}
+	_, err := ParseFormat("xml")
+	assert.NotNil(t, err)
+	assert.True(t, TSV.Tabular())
+	assert.False(t, YAML.Tabular())
+}
+
+func TestEncode(t *testing.T) {
+	var b bytes.Buffer
+	assert.Nil(t, Encode(&b, JSON, NewStrip(testStrips[0])))
+	var decoded map[string]interface{}
+	assert.Nil(t, json.Unmarshal(b.Bytes(), &decoded))
+	assert.Equal(t, "https://xkcd.com/327", decoded["url"])
+	assert.Equal(t, []interface{}{}, decoded["notes"])
+	assert.Equal(t, testStrips[0].Comment, decoded["alt"])
+
+	b.Reset()
+	assert.Nil(t, Encode(&b, JSONL, NewStrip(testStrips[1])))
+	assert.Equal(t, 1, strings.Count(b.String(), "\n"))
+
+	b.Reset()
+	assert.Nil(t, Encode(&b, YAML, &Search{Query: "perl", Results: []*Result{}}))
+	var search Search
+	assert.Nil(t, yaml.Unmarshal(b.Bytes(), &search))
+	assert.Equal(t, "perl", search.Query)
+
+	assert.NotNil(t, Encode(&b, CSV, NewStrip(testStrips[0])))
+}
+
+// writeAll prints the test strips in a format.
+func writeAll(t *testing.T, format Format, strips []*database.XKCDStrip) string {
+	var b bytes.Buffer
+	w, err := NewWriter(&b, format, StripColumns)
+	if !assert.Nil(t, err) {
+		return ""
+	}
+	for _, strip := range strips {
+		assert.Nil(t, w.Write(NewStrip(strip)))
+	}
+	assert.Nil(t, w.Close())
+	return b.String()
+}
+
+func TestWriter(t *testing.T) {
+	for _, strips := range [][]*database.XKCDStrip{nil, testStrips} {
+		var fromJSON []Strip
+		assert.Nil(t, json.Unmarshal([]byte(writeAll(t, JSON, strips)), &fromJSON))
+		assert.Len(t, fromJSON, len(strips))
+
+		var fromYAML []Strip
+		assert.Nil(t, yaml.Unmarshal([]byte(writeAll(t, YAML, strips)), &fromYAML))
+		assert.Len(t, fromYAML, len(strips))
+		assert.Equal(t, fromJSON, fromYAML)
+
+		rows, err := csv.NewReader(strings.NewReader(writeAll(t, CSV, strips))).ReadAll()
+		assert.Nil(t, err)
+		assert.Len(t, rows, len(strips)+1)
+		assert.Equal(t, StripColumns, rows[0])
+
+		lines := strings.Split(strings.TrimSuffix(writeAll(t, TSV, strips), "\n"), "\n")
+		assert.Len(t, lines, len(strips)+1)
+		for _, line := range lines {
+			assert.Len(t, strings.Split(line, "\t"), len(StripColumns))
+		}
+	}
+	jsonl := writeAll(t, JSONL, testStrips)
+	assert.Equal(t, 2, strings.Count(jsonl, "\n"))
+	assert.Equal(t, "", writeAll(t, JSONL, nil))
+
+	rows, _ := csv.NewReader(strings.NewReader(writeAll(t, CSV, testStrips))).ReadAll()
+	assert.Equal(t, testStrips[1].Transcript, rows[2][6])
+	assert.Equal(t, "regex, again", rows[2][9])
+
+	_, err := NewWriter(&bytes.Buffer{}, Text, StripColumns)
+	assert.NotNil(t, err)
+}
+
+func TestRefresh(t *testing.T) {
+	r := Refresh{Latest: 10, Downloaded: []int{9, 7}, Failed: []int{8}}
+	var ids []int
+	for _, strip := range r.Strips() {
+		ids = append(ids, strip.ID)
+	}
+	assert.Equal(t, []int{7, 8, 9}, ids)
+	assert.Equal(t, []string{"8", "failed"}, r.Strips()[1].Values())
+}
+
+func TestNewSearch(t *testing.T) {
+	results := &database.SearchResults{Total: 5, Above: 3, Hits: []*database.Hit{{Strip: testStrips[1], Score: 0.5}}}
+	s := NewSearch("perl", &database.SearchOpts{From: 2, MinScore: 0.1, Fuzziness: 1}, results)
+	assert.Equal(t, "perl", s.Query)
+	assert.Equal(t, 3, s.Above)
+	assert.True(t, s.Fuzzy)
+	assert.Equal(t, 3, s.Results[0].Rank)
+	assert.Equal(t, []Fragment{}, s.Results[0].Fragments)
+	assert.Equal(t, []*Result{}, NewSearch("none", &database.SearchOpts{}, &database.SearchResults{}).Results)
+}
+
+func TestNewRelated(t *testing.T) {
+	results := &database.SearchResults{Total: 2, Hits: []*database.Hit{{Strip: testStrips[0], Score: 0.7}, {Strip: testStrips[1], Score: 0.5}}}
+	r := NewRelated(327, results)
+	assert.Equal(t, 327, r.ID)
+	assert.Len(t, r.Results, 2)
+	assert.Equal(t, 2, r.Results[1].Rank)
+	assert.Equal(t, []*Result{}, NewRelated(327, &database.SearchResults{}).Results)
+}
@@ -0,0 +1,192 @@
+package output
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/lavagetto/xkcli/database"
+)
+
+// The documents printed by xkcli in the machine-readable formats. Their fields
+// are a stable interface for scripts: they won't be renamed or removed, and
+// lists are always present, even when empty.
+
+// StripColumns are the columns of the tables of strips.
+var StripColumns = database.StripFields
+
+// Strip is a strip.
+type Strip struct {
+	ID         int      `json:"id" yaml:"id"`
+	Title      string   `json:"title" yaml:"title"`
+	Date       string   `json:"date" yaml:"date"`
+	URL        string   `json:"url" yaml:"url"`
+	Img        string   `json:"img" yaml:"img"`
+	Alt        string   `json:"alt" yaml:"alt"`
+	Transcript string   `json:"transcript" yaml:"transcript"`
+	Tags       []string `json:"tags" yaml:"tags"`
+	Favourite  bool     `json:"favourite" yaml:"favourite"`
+	Notes      []string `json:"notes" yaml:"notes"`
+}
+
+// NewStrip returns the output representation of a strip.
+func NewStrip(x *database.XKCDStrip) *Strip {
+	strip := &Strip{
+		ID:         x.ID,
+		Title:      x.Title,
+		Date:       x.Date,
+		URL:        x.URL(),
+		Img:        x.Img,
+		Alt:        x.Comment,
+		Transcript: x.Transcript,
+		Tags:       append([]string{}, x.Tags...),
+		Favourite:  x.Favourite,
+		Notes:      append([]string{}, x.Notes...),
+	}
+	return strip
+}
+
+// Values implements Record, in the order of StripColumns.
+func (s *Strip) Values() []string {
+	x := database.XKCDStrip{
+		ID:         s.ID,
+		Title:      s.Title,
+		Date:       s.Date,
+		Img:        s.Img,
+		Comment:    s.Alt,
+		Transcript: s.Transcript,
+		Tags:       s.Tags,
+		Favourite:  s.Favourite,
+		Notes:      s.Notes,
+	}
+	values := make([]string, len(StripColumns))
+	for i, column := range StripColumns {
+		values[i], _ = x.Field(column)
+	}
+	return values
+}
+
+// Fragment is a part of a field matching a search.
+type Fragment struct {
+	Field string `json:"field" yaml:"field"`
+	Text  string `json:"text" yaml:"text"`
+}
+
+// ResultColumns are the columns of the tables of search results.
+var ResultColumns = append([]string{"rank", "score"}, StripColumns...)
+
+// Result is a strip found by a search.
+type Result struct {
+	// Rank is the position of the result among all the results, from 1.
+	Rank      int        `json:"rank" yaml:"rank"`
+	Score     float64    `json:"score" yaml:"score"`
+	Strip     *Strip     `json:"strip" yaml:"strip"`
+	Fragments []Fragment `json:"fragments" yaml:"fragments"`
+}
+
+// NewResult returns the output representation of a search hit.
+func NewResult(rank int, hit *database.Hit) *Result {
+	result := &Result{
+		Rank:      rank,
+		Score:     hit.Score,
+		Strip:     NewStrip(hit.Strip),
+		Fragments: []Fragment{},
+	}
+	for _, fragment := range hit.Fragments {
+		result.Fragments = append(result.Fragments, Fragment{Field: fragment.Field, Text: fragment.Text})
+	}
+	return result
+}
+
+// Values implements Record, in the order of ResultColumns.
+func (r *Result) Values() []string {
+	return append([]string{strconv.Itoa(r.Rank), strconv.FormatFloat(r.Score, 'f', -1, 64)}, r.Strip.Values()...)
+}
+
+// Search is the result of a search.
+type Search struct {
+	Query string `json:"query" yaml:"query"`
+	// Total is the number of strips matching the query.
+	Total int `json:"total" yaml:"total"`
+	// Above is the number of strips scoring at least MinScore.
+	Above    int     `json:"above" yaml:"above"`
+	MinScore float64 `json:"minScore" yaml:"minScore"`
+	// Fuzzy is true if the results come from a fuzzy search.
+	Fuzzy   bool      `json:"fuzzy" yaml:"fuzzy"`
+	Results []*Result `json:"results" yaml:"results"`
+	// Facets is only present if requested.
+	Facets *database.Facets `json:"facets,omitempty" yaml:"facets,omitempty"`
+}
+
+// NewSearch returns the output representation of the results of a search;
+// opts are the options the search was run with.
+func NewSearch(queryStr string, opts *database.SearchOpts, results *database.SearchResults) *Search {
+	search := &Search{
+		Query:    queryStr,
+		Total:    results.Total,
+		Above:    results.Above,
+		MinScore: opts.MinScore,
+		Fuzzy:    opts.Fuzziness > 0,
+		Results:  []*Result{},
+	}
+	for pos, hit := range results.Hits {
+		search.Results = append(search.Results, NewResult(opts.From+pos+1, hit))
+	}
+	return search
+}
+
+// Related are the strips most similar to a strip.
+type Related struct {
+	ID      int       `json:"id" yaml:"id"`
+	Results []*Result `json:"results" yaml:"results"`
+}
+
+// NewRelated returns the output representation of the strips related to the
+// strip id.
+func NewRelated(id int, results *database.SearchResults) *Related {
+	related := &Related{ID: id, Results: []*Result{}}
+	for pos, hit := range results.Hits {
+		related.Results = append(related.Results, NewResult(pos+1, hit))
+	}
+	return related
+}
+
+// RefreshColumns are the columns of the tables of refreshed strips.
+var RefreshColumns = []string{"id", "status"}
+
+// The status of a strip after a refresh.
+const (
+	Downloaded = "downloaded"
+	Failed     = "failed"
+)
+
+// RefreshedStrip is a strip a refresh tried to download.
+type RefreshedStrip struct {
+	ID     int    `json:"id" yaml:"id"`
+	Status string `json:"status" yaml:"status"`
+}
+
+// Values implements Record, in the order of RefreshColumns.
+func (r *RefreshedStrip) Values() []string {
+	return []string{strconv.Itoa(r.ID), r.Status}
+}
+
+// Refresh is the report of a refresh.
+type Refresh struct {
+	// Latest is the ID of the latest strip published.
+	Latest     int   `json:"latest" yaml:"latest"`
+	Downloaded []int `json:"downloaded" yaml:"downloaded"`
+	Failed     []int `json:"failed" yaml:"failed"`
+}
+
+// Strips returns the strips the refresh tried to download, in order.
+func (r *Refresh) Strips() []*RefreshedStrip {
+	var strips []*RefreshedStrip
+	for _, id := range r.Downloaded {
+		strips = append(strips, &RefreshedStrip{ID: id, Status: Downloaded})
+	}
+	for _, id := range r.Failed {
+		strips = append(strips, &RefreshedStrip{ID: id, Status: Failed})
+	}
+	sort.Slice(strips, func(i, j int) bool { return strips[i].ID < strips[j].ID })
+	return strips
+}
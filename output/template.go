@@ -0,0 +1,174 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lavagetto/xkcli/database"
+)
+
+// DefaultTemplate is the name of the preset used if the user doesn't choose a template.
+const DefaultTemplate = "default"
+
+// Presets are the named templates of the summaries of the strips.
+var Presets = map[string]string{
+	DefaultTemplate: `{{if .Rank}}{{.Rank}} - ({{printf "%.2f" .Score}}) {{end}}XKCD {{.ID}} ({{.Date}}): {{.Title}}
+	strip: {{.Img}}
+{{range .Fragments}}	{{.Field}}: {{.Text}}
+{{end}}{{if .Tags}}	tags: {{join ", " .Tags}}
+{{end}}{{if .Favourite}}	★ favourite
+{{end}}{{range .Notes}}	note: {{.}}
+{{end}}`,
+	"compact":  `{{if .Rank}}{{.Rank}}. {{end}}{{.ID}} {{.Title}} ({{.Date}}) {{.URL}}`,
+	"irc":      `xkcd {{.ID}}: {{.Title}} - {{.URL}}{{if .Alt}} - {{truncate 150 .Alt}}{{end}}`,
+	"markdown": `{{if .Rank}}{{.Rank}}.{{else}}-{{end}} [xkcd {{.ID}}: {{.Title}}]({{.URL}}) ({{date "January 2, 2006" .Date}})`,
+}
+
+// PresetNames returns the names of the presets, in order.
+func PresetNames() []string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Summary is what the templates of the summaries can use: all the fields of
+// the strip, like .Title or .URL, and its rank and score in a search.
+// Rank and Score are zero if the strip doesn't come from a search.
+type Summary struct {
+	*Strip
+	Rank      int
+	Score     float64
+	Fragments []Fragment
+}
+
+// NewSummary returns the summary of a strip outside of a search.
+func NewSummary(strip *database.XKCDStrip) *Summary {
+	return &Summary{Strip: NewStrip(strip), Fragments: []Fragment{}}
+}
+
+// NewHitSummary returns the summary of a strip found by a search.
+func NewHitSummary(rank int, hit *database.Hit) *Summary {
+	result := NewResult(rank, hit)
+	return &Summary{Strip: result.Strip, Rank: rank, Score: hit.Score, Fragments: result.Fragments}
+}
+
+// colours are the ANSI escape codes of the colours templates can use.
+var colours = map[string]string{
+	"bold":    "\x1b[1m",
+	"dim":     "\x1b[2m",
+	"red":     "\x1b[31m",
+	"green":   "\x1b[32m",
+	"yellow":  "\x1b[33m",
+	"blue":    "\x1b[34m",
+	"magenta": "\x1b[35m",
+	"cyan":    "\x1b[36m",
+}
+
+// Template prints the summaries of the strips.
+type Template struct {
+	tmpl *template.Template
+}
+
+// ParseTemplate parses either the name of a preset or the text of a template.
+// If colour is false, the colour function of the template prints the text unchanged.
+//
+// Besides the functions built in text/template, templates can use:
+//   - truncate N TEXT, to cut TEXT to N characters;
+//   - wrap WIDTH TEXT, to wrap TEXT in lines of at most WIDTH characters;
+//   - date LAYOUT DATE, to format a date with a Go time layout, like "Jan 2, 2006";
+//   - colour NAME TEXT (or color), to show TEXT in bold, dim, red, green, yellow, blue, magenta or cyan;
+//   - join SEPARATOR LIST, upper TEXT and lower TEXT.
+func ParseTemplate(nameOrText string, colour bool) (*Template, error) {
+	text, ok := Presets[nameOrText]
+	if !ok {
+		text = nameOrText
+	}
+	paint := func(name string, value interface{}) (string, error) {
+		code, ok := colours[name]
+		if !ok {
+			return "", fmt.Errorf("unknown colour %q", name)
+		}
+		if !colour {
+			return fmt.Sprint(value), nil
+		}
+		return code + fmt.Sprint(value) + "\x1b[0m", nil
+	}
+	funcs := template.FuncMap{
+		"truncate": truncate,
+		"wrap":     wrap,
+		"date":     formatDate,
+		"colour":   paint,
+		"color":    paint,
+		"join":     func(sep string, list []string) string { return strings.Join(list, sep) },
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+	}
+	tmpl, err := template.New("summary").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %s", err)
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Execute prints a summary, ending it with a newline if the template doesn't.
+func (t *Template) Execute(w io.Writer, summary *Summary) error {
+	var b bytes.Buffer
+	if err := t.tmpl.Execute(&b, summary); err != nil {
+		return fmt.Errorf("invalid template: %s", err)
+	}
+	if b.Len() > 0 && !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// truncate cuts a text to at most n characters, ending it with an ellipsis if needed.
+func truncate(n int, text string) string {
+	if n < 1 || utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+// wrap breaks a text in lines of at most width characters, between words.
+// Longer words are left on a line of their own.
+func wrap(width int, text string) string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width:
+				lines = append(lines, line)
+				line = word
+			default:
+				line += " " + word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatDate formats the date of a strip with a Go time layout; dates that
+// can't be parsed are returned unchanged.
+func formatDate(layout, date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return t.Format(layout)
+}
@@ -0,0 +1,55 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func execute(t *testing.T, text string, colour bool, summary *Summary) string {
+	tmpl, err := ParseTemplate(text, colour)
+	assert.Nil(t, err)
+	var b bytes.Buffer
+	assert.Nil(t, tmpl.Execute(&b, summary))
+	return b.String()
+}
+
+func TestDefaultTemplate(t *testing.T) {
+	// The default template prints the same summary as the strip.
+	strip := &database.XKCDStrip{ID: 1838, Title: "Machine Learning", Date: "2017-05-17", Img: "https://imgs.xkcd.com/comics/machine_learning.png"}
+	assert.Equal(t, strip.Summary(), execute(t, DefaultTemplate, false, NewSummary(strip)))
+	hit := &database.Hit{Strip: testStrips[0], Score: 1.234, Fragments: []database.Fragment{{Field: "title", Text: "**Mom**"}}}
+	assert.Equal(t, "3 - (1.23) "+testStrips[0].Summary()+"\ttitle: **Mom**\n\ttags: sql\n", execute(t, DefaultTemplate, false, NewHitSummary(3, hit)))
+}
+
+func TestPresets(t *testing.T) {
+	summary := NewHitSummary(1, &database.Hit{Strip: testStrips[0], Score: 1})
+	assert.Equal(t, "1. 327 Exploits of a Mom (2007-10-10) https://xkcd.com/327\n", execute(t, "compact", false, summary))
+	assert.Equal(t, "1. [xkcd 327: Exploits of a Mom](https://xkcd.com/327) (October 10, 2007)\n", execute(t, "markdown", false, summary))
+	assert.Equal(t, "- [xkcd 327: Exploits of a Mom](https://xkcd.com/327) (October 10, 2007)\n", execute(t, "markdown", false, NewSummary(testStrips[0])))
+	assert.Contains(t, execute(t, "irc", false, summary), "xkcd 327: Exploits of a Mom - https://xkcd.com/327 - Her daughter")
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	summary := NewSummary(testStrips[0])
+	assert.Equal(t, "Exploits…\n", execute(t, "{{truncate 9 .Title}}", false, summary))
+	assert.Equal(t, "Exploits of\na Mom\n", execute(t, "{{wrap 12 .Title}}", false, summary))
+	assert.Equal(t, "10/10/07\n", execute(t, `{{date "01/02/06" .Date}}`, false, summary))
+	assert.Equal(t, "EXPLOITS OF A MOM sql\n", execute(t, `{{upper .Title}} {{join "," .Tags}}`, false, summary))
+	assert.Equal(t, "327\n", execute(t, `{{.ID | colour "red"}}`, false, summary))
+	assert.Equal(t, "\x1b[31m327\x1b[0m\n", execute(t, `{{.ID | color "red"}}`, true, summary))
+}
+
+func TestTemplateErrors(t *testing.T) {
+	_, err := ParseTemplate("{{.Title", false)
+	assert.NotNil(t, err)
+	tmpl, err := ParseTemplate(`{{colour "pink" .Title}}`, false)
+	assert.Nil(t, err)
+	var b bytes.Buffer
+	assert.NotNil(t, tmpl.Execute(&b, NewSummary(testStrips[0])))
+	tmpl, err = ParseTemplate("{{.Nope}}", false)
+	assert.Nil(t, err)
+	assert.NotNil(t, tmpl.Execute(&b, NewSummary(testStrips[0])))
+}
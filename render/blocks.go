@@ -0,0 +1,44 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// renderBlocks draws the image with upper half blocks: each character shows
+// two pixels, the top one in the foreground colour and the bottom one in the
+// background colour.
+func renderBlocks(w io.Writer, img image.Image, size Size, trueColour bool) error {
+	bounds := img.Bounds()
+	columns := size.columns(bounds.Dx())
+	cellWidth, cellHeight := size.cell()
+	// Each character is two pixels high: keep the proportions of the image.
+	height := columns * bounds.Dy() * 2 * cellWidth / (bounds.Dx() * cellHeight)
+	if height < 2 {
+		height = 2
+	}
+	if height%2 == 1 {
+		height++
+	}
+	scaled := scale(img, columns, height)
+	out := bufio.NewWriter(w)
+	for y := 0; y < height; y += 2 {
+		for x := 0; x < columns; x++ {
+			fmt.Fprintf(out, "%s%s▀", blockColour(38, scaled.RGBAAt(x, y), trueColour), blockColour(48, scaled.RGBAAt(x, y+1), trueColour))
+		}
+		fmt.Fprint(out, "\x1b[0m\n")
+	}
+	return out.Flush()
+}
+
+// blockColour returns the escape sequence setting a colour: 38 is the foreground, 48 the background.
+func blockColour(ground int, c color.RGBA, trueColour bool) string {
+	if trueColour {
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", ground, c.R, c.G, c.B)
+	}
+	// The palette starts after the 16 basic colours.
+	return fmt.Sprintf("\x1b[%d;5;%dm", ground, 16+paletteIndex(c))
+}
@@ -0,0 +1,179 @@
+// Package render shows images in a terminal.
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// Protocol is a way of showing images in a terminal.
+type Protocol string
+
+// The protocols supported. Kitty, ITerm and Sixel are understood only by some
+// terminals; Blocks draws the image with coloured Unicode half blocks, and
+// works almost everywhere.
+const (
+	Auto   Protocol = "auto"
+	Kitty  Protocol = "kitty"
+	ITerm  Protocol = "iterm"
+	Sixel  Protocol = "sixel"
+	Blocks Protocol = "blocks"
+	None   Protocol = "none"
+)
+
+// Protocols lists all the supported protocols.
+var Protocols = []Protocol{Auto, Kitty, ITerm, Sixel, Blocks, None}
+
+// ParseProtocol validates the name of a protocol.
+func ParseProtocol(name string) (Protocol, error) {
+	names := make([]string, len(Protocols))
+	for i, p := range Protocols {
+		if string(p) == name {
+			return p, nil
+		}
+		names[i] = string(p)
+	}
+	return "", fmt.Errorf("unknown image protocol %q: use one of %s", name, strings.Join(names, ", "))
+}
+
+// Detect guesses the best protocol the terminal supports from its
+// environment variables, read with getenv.
+func Detect(getenv func(string) string) Protocol {
+	term := getenv("TERM")
+	program := getenv("TERM_PROGRAM")
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || term == "xterm-ghostty" || program == "ghostty":
+		return Kitty
+	case program == "iTerm.app" || getenv("LC_TERMINAL") == "iTerm2" || program == "WezTerm":
+		return ITerm
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "mlterm") || strings.HasPrefix(term, "foot") ||
+		strings.HasPrefix(term, "yaft") || program == "mlterm":
+		return Sixel
+	}
+	return Blocks
+}
+
+// Size is the size of the terminal. The size in pixels is 0 if unknown.
+type Size struct {
+	Columns int
+	Rows    int
+	Width   int
+	Height  int
+}
+
+// The defaults used when the size of the terminal is unknown.
+const (
+	defaultColumns    = 80
+	defaultCellWidth  = 10
+	defaultCellHeight = 20
+)
+
+// cell returns the size of a character cell, in pixels.
+func (s Size) cell() (int, int) {
+	if s.Columns <= 0 || s.Rows <= 0 || s.Width <= 0 || s.Height <= 0 {
+		return defaultCellWidth, defaultCellHeight
+	}
+	return s.Width / s.Columns, s.Height / s.Rows
+}
+
+// columns returns how many columns an image as wide as width pixels takes:
+// its natural width, but no more than the width of the terminal.
+func (s Size) columns(width int) int {
+	max := s.Columns
+	if max <= 0 {
+		max = defaultColumns
+	}
+	cellWidth, _ := s.cell()
+	columns := (width + cellWidth - 1) / cellWidth
+	if columns > max {
+		columns = max
+	}
+	if columns < 1 {
+		columns = 1
+	}
+	return columns
+}
+
+// Options control how images are shown.
+type Options struct {
+	Protocol Protocol
+	Size     Size
+	// TrueColour is set if the terminal supports 24-bit colours; otherwise
+	// Blocks uses the 256 colours palette.
+	TrueColour bool
+}
+
+// Render shows an image with the protocol chosen, scaled to fit the terminal.
+func Render(w io.Writer, img image.Image, opts *Options) error {
+	switch opts.Protocol {
+	case Kitty:
+		return renderKitty(w, img, opts.Size)
+	case ITerm:
+		return renderITerm(w, img, opts.Size)
+	case Sixel:
+		return renderSixel(w, img, opts.Size)
+	case Blocks:
+		return renderBlocks(w, img, opts.Size, opts.TrueColour)
+	case None:
+		return nil
+	}
+	return fmt.Errorf("can't render images with the protocol %q", opts.Protocol)
+}
+
+// encodePNG returns the image as PNG, which all the graphic protocols accept.
+func encodePNG(img image.Image) ([]byte, error) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// kittyChunk is the largest payload of a kitty graphics command.
+const kittyChunk = 4096
+
+// renderKitty sends the image with the kitty graphics protocol, in chunks,
+// letting the terminal scale it to the given number of columns.
+func renderKitty(w io.Writer, img image.Image, size Size) error {
+	data, err := encodePNG(img)
+	if err != nil {
+		return err
+	}
+	payload := base64.StdEncoding.EncodeToString(data)
+	for first := true; first || payload != ""; first = false {
+		chunk := payload
+		if len(chunk) > kittyChunk {
+			chunk = chunk[:kittyChunk]
+		}
+		payload = payload[len(chunk):]
+		more := 0
+		if payload != "" {
+			more = 1
+		}
+		control := fmt.Sprintf("m=%d", more)
+		if first {
+			control = fmt.Sprintf("a=T,f=100,q=2,c=%d,%s", size.columns(img.Bounds().Dx()), control)
+		}
+		if _, err := fmt.Fprintf(w, "\x1b_G%s;%s\x1b\\", control, chunk); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
+
+// renderITerm sends the image with the inline images protocol of iTerm2.
+func renderITerm(w io.Writer, img image.Image, size Size) error {
+	data, err := encodePNG(img)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d;width=%d;preserveAspectRatio=1:%s\a\n",
+		len(data), size.columns(img.Bounds().Dx()), base64.StdEncoding.EncodeToString(data))
+	return err
+}
@@ -0,0 +1,139 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testImage is black on the left half, and white on the right one.
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{A: 0xff}
+			if x >= width/2 {
+				c = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func env(values map[string]string) func(string) string {
+	return func(name string) string { return values[name] }
+}
+
+func TestParseProtocol(t *testing.T) {
+	for _, p := range Protocols {
+		parsed, err := ParseProtocol(string(p))
+		assert.Nil(t, err)
+		assert.Equal(t, p, parsed)
+	}
+	_, err := ParseProtocol("ascii")
+	assert.NotNil(t, err)
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, Kitty, Detect(env(map[string]string{"TERM": "xterm-kitty"})))
+	assert.Equal(t, Kitty, Detect(env(map[string]string{"TERM": "xterm-256color", "KITTY_WINDOW_ID": "1"})))
+	assert.Equal(t, ITerm, Detect(env(map[string]string{"TERM_PROGRAM": "iTerm.app"})))
+	assert.Equal(t, Sixel, Detect(env(map[string]string{"TERM": "foot"})))
+	assert.Equal(t, Blocks, Detect(env(map[string]string{"TERM": "xterm-256color"})))
+	assert.Equal(t, Blocks, Detect(env(nil)))
+}
+
+func TestColumns(t *testing.T) {
+	// Without a known size, cells are 10 pixels wide and the terminal has 80 columns.
+	assert.Equal(t, 40, Size{}.columns(400))
+	assert.Equal(t, 80, Size{}.columns(2000))
+	size := Size{Columns: 100, Rows: 50, Width: 800, Height: 800}
+	assert.Equal(t, 50, size.columns(400))
+	assert.Equal(t, 100, size.columns(2000))
+}
+
+func TestScale(t *testing.T) {
+	scaled := scale(testImage(40, 20), 4, 2)
+	assert.Equal(t, image.Rect(0, 0, 4, 2), scaled.Bounds())
+	assert.Equal(t, color.RGBA{A: 0xff}, scaled.RGBAAt(0, 1))
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, scaled.RGBAAt(3, 0))
+	// Transparent pixels become white.
+	transparent := scale(image.NewRGBA(image.Rect(0, 0, 2, 2)), 1, 1)
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, transparent.RGBAAt(0, 0))
+}
+
+func TestPalette(t *testing.T) {
+	for _, i := range []int{0, 17, 215, 216, 239} {
+		assert.Equal(t, i, paletteIndex(paletteColour(i)))
+	}
+	assert.Equal(t, 0, paletteIndex(color.RGBA{A: 0xff}))
+	assert.Equal(t, 215, paletteIndex(color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}))
+	assert.Equal(t, 180, paletteIndex(color.RGBA{R: 0xff, A: 0xff}))
+}
+
+func TestBlocks(t *testing.T) {
+	var b bytes.Buffer
+	assert.Nil(t, Render(&b, testImage(40, 20), &Options{Protocol: Blocks, TrueColour: true}))
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	// 4 columns, and 2 pixels for each of the 2 rows of characters.
+	assert.Equal(t, 1, len(lines))
+	assert.Equal(t, 4, strings.Count(lines[0], "▀"))
+	assert.True(t, strings.HasPrefix(lines[0], "\x1b[38;2;0;0;0m\x1b[48;2;0;0;0m▀"))
+	assert.True(t, strings.HasSuffix(lines[0], "\x1b[38;2;255;255;255m\x1b[48;2;255;255;255m▀\x1b[0m"))
+	b.Reset()
+	assert.Nil(t, Render(&b, testImage(40, 40), &Options{Protocol: Blocks}))
+	assert.Equal(t, 2, strings.Count(b.String(), "\n"))
+	assert.Contains(t, b.String(), "\x1b[38;5;231m")
+}
+
+func TestSixel(t *testing.T) {
+	var b bytes.Buffer
+	assert.Nil(t, Render(&b, testImage(8, 12), &Options{Protocol: Sixel}))
+	// Two bands of 6 rows, each with the black half and the white half.
+	assert.Equal(t, "\x1bP0;1;0q\"1;1;8;12#0;2;0;0;0#215;2;100;100;100"+
+		"#0!4~!4?$#215!4?!4~-#0!4~!4?$#215!4?!4~-\x1b\\\n", b.String())
+	assert.Equal(t, "!5~?~~", runLength([]byte("~~~~~?~~")))
+}
+
+func TestKitty(t *testing.T) {
+	var b bytes.Buffer
+	img := testImage(800, 600)
+	assert.Nil(t, Render(&b, img, &Options{Protocol: Kitty, Size: Size{Columns: 50}}))
+	out := b.String()
+	assert.True(t, strings.HasPrefix(out, "\x1b_Ga=T,f=100,q=2,c=50,m="))
+	// The chunks add up to the image, as a PNG.
+	var payload strings.Builder
+	for _, command := range strings.Split(strings.TrimSuffix(out, "\n"), "\x1b\\") {
+		if command == "" {
+			continue
+		}
+		payload.WriteString(command[strings.Index(command, ";")+1:])
+	}
+	data, err := base64.StdEncoding.DecodeString(payload.String())
+	assert.Nil(t, err)
+	decoded, err := png.Decode(bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, img.Bounds(), decoded.Bounds())
+}
+
+func TestITerm(t *testing.T) {
+	var b bytes.Buffer
+	assert.Nil(t, Render(&b, testImage(100, 50), &Options{Protocol: ITerm}))
+	assert.True(t, strings.HasPrefix(b.String(), "\x1b]1337;File=inline=1;size="))
+	assert.Contains(t, b.String(), ";width=10;preserveAspectRatio=1:")
+	assert.True(t, strings.HasSuffix(b.String(), "\a\n"))
+}
+
+func TestNone(t *testing.T) {
+	var b bytes.Buffer
+	assert.Nil(t, Render(&b, testImage(10, 10), &Options{Protocol: None}))
+	assert.Equal(t, "", b.String())
+	assert.NotNil(t, Render(&b, testImage(10, 10), &Options{Protocol: Auto}))
+}
@@ -0,0 +1,116 @@
+package render
+
+import (
+	"image"
+	"image/color"
+)
+
+// scale resizes an image to width×height pixels, averaging the pixels of
+// the source covered by each pixel of the result. Transparent parts are
+// shown on white, like on the site.
+func scale(img image.Image, width, height int) *image.RGBA {
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	bounds := img.Bounds()
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, n uint32
+			for sy := y0; sy < y1 && sy < bounds.Max.Y; sy++ {
+				for sx := x0; sx < x1 && sx < bounds.Max.X; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					// Colours are premultiplied: add white where the pixel is transparent.
+					white := 0xffff - pa
+					r += (pr + white) >> 8
+					g += (pg + white) >> 8
+					b += (pb + white) >> 8
+					n++
+				}
+			}
+			if n == 0 {
+				n = 1
+			}
+			scaled.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: 0xff})
+		}
+	}
+	return scaled
+}
+
+// The palette of sixels, and of terminals without 24-bit colours: the
+// 6×6×6 cube and the 24 grays of the 256 colours of xterm.
+const (
+	cubeColours = 216
+	grayColours = 24
+)
+
+// cubeLevels are the intensities of the components of the colour cube.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// paletteIndex returns the index in the palette of the closest colour to c.
+func paletteIndex(c color.RGBA) int {
+	level := func(v uint8) int {
+		best := 0
+		for i, l := range cubeLevels {
+			if abs(int(v)-l) < abs(int(v)-cubeLevels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	r, g, b := level(c.R), level(c.G), level(c.B)
+	cube := 36*r + 6*g + b
+	// Grays go from 8 to 238 by 10.
+	average := (int(c.R) + int(c.G) + int(c.B)) / 3
+	gray := (average - 3) / 10
+	if gray < 0 {
+		gray = 0
+	}
+	if gray >= grayColours {
+		gray = grayColours - 1
+	}
+	if distance(c, paletteColour(cubeColours+gray)) < distance(c, paletteColour(cube)) {
+		return cubeColours + gray
+	}
+	return cube
+}
+
+// paletteColour returns the colour with the given index in the palette.
+func paletteColour(i int) color.RGBA {
+	if i >= cubeColours {
+		v := uint8(8 + 10*(i-cubeColours))
+		return color.RGBA{R: v, G: v, B: v, A: 0xff}
+	}
+	return color.RGBA{
+		R: uint8(cubeLevels[i/36]),
+		G: uint8(cubeLevels[i/6%6]),
+		B: uint8(cubeLevels[i%6]),
+		A: 0xff,
+	}
+}
+
+// distance is the squared distance between two colours.
+func distance(a, b color.RGBA) int {
+	dr, dg, db := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B)
+	return dr*dr + dg*dg + db*db
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,105 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strings"
+)
+
+// renderSixel draws the image with sixels: bands six pixels high, where each
+// character sets the pixels of a column in one of the colours of the palette.
+func renderSixel(w io.Writer, img image.Image, size Size) error {
+	bounds := img.Bounds()
+	cellWidth, _ := size.cell()
+	width := size.columns(bounds.Dx()) * cellWidth
+	if width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+	height := width * bounds.Dy() / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	scaled := scale(img, width, height)
+	pixels := make([]int, width*height)
+	used := make(map[int]bool)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := paletteIndex(scaled.RGBAAt(x, y))
+			pixels[y*width+x] = i
+			used[i] = true
+		}
+	}
+	out := bufio.NewWriter(w)
+	// Pixels have a 1:1 aspect ratio, and the background is left alone.
+	fmt.Fprintf(out, "\x1bP0;1;0q\"1;1;%d;%d", width, height)
+	colours := make([]int, 0, len(used))
+	for i := range used {
+		colours = append(colours, i)
+	}
+	sort.Ints(colours)
+	for _, i := range colours {
+		c := paletteColour(i)
+		fmt.Fprintf(out, "#%d;2;%d;%d;%d", i, int(c.R)*100/255, int(c.G)*100/255, int(c.B)*100/255)
+	}
+	for top := 0; top < height; top += 6 {
+		colours := bandColours(pixels, width, height, top)
+		for n, i := range colours {
+			fmt.Fprintf(out, "#%d", i)
+			line := make([]byte, width)
+			for x := 0; x < width; x++ {
+				bits := 0
+				for dy := 0; dy < 6 && top+dy < height; dy++ {
+					if pixels[(top+dy)*width+x] == i {
+						bits |= 1 << uint(dy)
+					}
+				}
+				line[x] = byte(63 + bits)
+			}
+			out.WriteString(runLength(line))
+			if n < len(colours)-1 {
+				// Go back to the start of the band for the next colour.
+				out.WriteByte('$')
+			}
+		}
+		out.WriteByte('-')
+	}
+	out.WriteString("\x1b\\\n")
+	return out.Flush()
+}
+
+// bandColours returns the colours used in the band of six rows starting at top.
+func bandColours(pixels []int, width, height, top int) []int {
+	seen := make(map[int]bool)
+	var colours []int
+	for y := top; y < top+6 && y < height; y++ {
+		for _, i := range pixels[y*width : (y+1)*width] {
+			if !seen[i] {
+				seen[i] = true
+				colours = append(colours, i)
+			}
+		}
+	}
+	sort.Ints(colours)
+	return colours
+}
+
+// runLength compresses repeated sixels, as in !12~ for twelve ~.
+func runLength(line []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		j := i
+		for j < len(line) && line[j] == line[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(&b, "!%d%c", n, line[i])
+		} else {
+			b.WriteString(strings.Repeat(string(line[i]), n))
+		}
+		i = j
+	}
+	return b.String()
+}
@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package render
+
+import (
+	"os"
+)
+
+// TerminalSize returns the size of the terminal f is attached to. It's
+// unknown on this system, so the defaults are used.
+func TerminalSize(f *os.File) (Size, bool) {
+	return Size{}, false
+}
@@ -0,0 +1,19 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package render
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// TerminalSize returns the size of the terminal f is attached to.
+func TerminalSize(f *os.File) (Size, bool) {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 {
+		return Size{}, false
+	}
+	return Size{Columns: int(ws.Col), Rows: int(ws.Row), Width: int(ws.Xpixel), Height: int(ws.Ypixel)}, true
+}
@@ -0,0 +1,319 @@
+// Package server exposes the strips in the database as an HTTP API returning
+// JSON, so that a team can share one index.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/output"
+	"go.uber.org/zap"
+)
+
+// DefaultLimit is the number of results of a search without a limit.
+const DefaultLimit = 10
+
+// Opener opens the repository for a request. The repository is opened anew
+// for every request, and closed after it, so that `xkcli refresh` can update
+// the database while the server is running.
+type Opener func() (database.Repository, error)
+
+// Options control the answers of the server.
+type Options struct {
+	// Search are the options of the searches; the parameters of the request
+	// replace the dates and the pagination.
+	Search database.SearchOpts
+	// FuzzyFallback is the edit distance of the fuzzy search run when no
+	// strip scores above Search.MinScore; 0 disables it.
+	FuzzyFallback int
+	// FuzzyMinScore is the minimum score of the results of fuzzy searches.
+	FuzzyMinScore float64
+	// MaxLimit, if positive, is the maximum number of results a search can
+	// return.
+	MaxLimit int
+}
+
+// Server answers the requests to the API:
+//
+//	GET /search?q=&limit=&offset=&since=&until=  the results of a search
+//	GET /strips/{id}                             a strip
+//	GET /random?q=&tag=                          a random strip
+//	GET /latest                                  the latest strip
+//	GET /healthz                                 the state of the database
+type Server struct {
+	open   Opener
+	opts   *Options
+	logger *zap.SugaredLogger
+	mux    *http.ServeMux
+}
+
+// New returns a server reading the strips from the repositories returned by
+// open, and logging the requests to logger.
+func New(open Opener, opts *Options, logger *zap.SugaredLogger) *Server {
+	s := &Server{open: open, opts: opts, logger: logger, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/search", s.withRepo(s.search))
+	s.mux.HandleFunc("/strips/", s.withRepo(s.strip))
+	s.mux.HandleFunc("/random", s.withRepo(s.random))
+	s.mux.HandleFunc("/latest", s.withRepo(s.latest))
+	s.mux.HandleFunc("/healthz", s.withRepo(s.healthz))
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	})
+	return s
+}
+
+// statusWriter remembers the status of a response, to log it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// ServeHTTP implements http.Handler, logging every request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		sw.Header().Set("Allow", "GET, HEAD")
+		writeError(sw, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	} else {
+		s.mux.ServeHTTP(sw, r)
+	}
+	s.logger.Infow("Request",
+		"method", r.Method,
+		"path", r.URL.RequestURI(),
+		"status", sw.status,
+		"duration", time.Since(start),
+		"remote", r.RemoteAddr,
+	)
+}
+
+// httpError is an error with the HTTP status to answer with.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e httpError) Error() string {
+	return e.err.Error()
+}
+
+// badRequest returns an error caused by the parameters of the request.
+func badRequest(format string, args ...interface{}) error {
+	return httpError{http.StatusBadRequest, fmt.Errorf(format, args...)}
+}
+
+// notFound returns an error for a strip that doesn't exist.
+func notFound(format string, args ...interface{}) error {
+	return httpError{http.StatusNotFound, fmt.Errorf(format, args...)}
+}
+
+// handler answers a request with the document it returns, encoded as JSON.
+type handler func(repo database.Repository, r *http.Request) (interface{}, error)
+
+// withRepo opens the repository for a handler, and writes its answer.
+func (s *Server) withRepo(h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := s.open()
+		if err != nil {
+			s.logger.Errorw("Could not open the database", "error", err)
+			writeError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		defer repo.Close()
+		doc, err := h(repo, r)
+		if err != nil {
+			status := http.StatusInternalServerError
+			var he httpError
+			var syntax database.QuerySyntaxError
+			switch {
+			case errors.As(err, &he):
+				status = he.status
+			case errors.As(err, &syntax):
+				status = http.StatusBadRequest
+			default:
+				s.logger.Errorw("Could not answer the request", "path", r.URL.Path, "error", err)
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, doc)
+	}
+}
+
+// writeJSON writes a document as the answer.
+func writeJSON(w http.ResponseWriter, status int, doc interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(doc)
+}
+
+// writeError answers with an error, as {"error": "..."}.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// dateParams returns the interval of dates requested by the since and until
+// parameters, interpreted like the flags of `xkcli search`.
+func dateParams(r *http.Request, opts *database.SearchOpts) error {
+	now := time.Now()
+	if since := r.FormValue("since"); since != "" {
+		start, _, err := database.ParseDateRange(since, now)
+		if err != nil {
+			return badRequest("invalid since: %s", err)
+		}
+		opts.Since = start
+	}
+	if until := r.FormValue("until"); until != "" {
+		_, end, err := database.ParseDateRange(until, now)
+		if err != nil {
+			return badRequest("invalid until: %s", err)
+		}
+		opts.Until = end
+	}
+	return nil
+}
+
+// intParam returns the value of an integer parameter, or def if it's missing.
+func intParam(r *http.Request, name string, def int) (int, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, badRequest("invalid %s %q: it must be a number", name, value)
+	}
+	return n, nil
+}
+
+// search answers GET /search with an output.Search document. Like `xkcli
+// search`, it falls back to a fuzzy search if nothing matches exactly.
+func (s *Server) search(repo database.Repository, r *http.Request) (interface{}, error) {
+	queryStr := strings.TrimSpace(r.FormValue("q"))
+	if queryStr == "" {
+		return nil, badRequest("the q parameter is required")
+	}
+	opts := s.opts.Search
+	if err := dateParams(r, &opts); err != nil {
+		return nil, err
+	}
+	var err error
+	if opts.MaxRecords, err = intParam(r, "limit", DefaultLimit); err != nil {
+		return nil, err
+	}
+	if opts.MaxRecords < 1 {
+		return nil, badRequest("the limit must be positive")
+	}
+	if s.opts.MaxLimit > 0 && opts.MaxRecords > s.opts.MaxLimit {
+		return nil, badRequest("the limit can't be more than %d", s.opts.MaxLimit)
+	}
+	if opts.From, err = intParam(r, "offset", 0); err != nil {
+		return nil, err
+	}
+	if opts.From < 0 {
+		return nil, badRequest("the offset can't be negative")
+	}
+	results, err := repo.Search(queryStr, &opts)
+	if err != nil {
+		return nil, err
+	}
+	if results.MaxScore < opts.MinScore && s.opts.FuzzyFallback > 0 {
+		opts.Fuzziness = s.opts.FuzzyFallback
+		opts.MinScore = s.opts.FuzzyMinScore
+		results, err = repo.Search(queryStr, &opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return output.NewSearch(queryStr, &opts, results), nil
+}
+
+// strip answers GET /strips/{id} with an output.Strip document.
+func (s *Server) strip(repo database.Repository, r *http.Request) (interface{}, error) {
+	value := strings.TrimPrefix(r.URL.Path, "/strips/")
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 1 {
+		return nil, badRequest("invalid strip number %q", value)
+	}
+	strip, err := repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if strip == nil {
+		return nil, notFound("strip %d is not in the database", id)
+	}
+	return output.NewStrip(strip), nil
+}
+
+// random answers GET /random with an output.Strip document, picked among
+// the strips matching the q and tag parameters, if present.
+func (s *Server) random(repo database.Repository, r *http.Request) (interface{}, error) {
+	opts := &database.RandomOpts{
+		Query:  strings.TrimSpace(r.FormValue("q")),
+		Tag:    r.FormValue("tag"),
+		Search: s.opts.Search,
+	}
+	if err := dateParams(r, &opts.Search); err != nil {
+		return nil, err
+	}
+	strip, err := database.Random(repo, opts, rand.New(rand.NewSource(time.Now().UnixNano())))
+	if err != nil {
+		return nil, err
+	}
+	if strip == nil {
+		return nil, notFound("no strip matches the filters")
+	}
+	return output.NewStrip(strip), nil
+}
+
+// latest answers GET /latest with the output.Strip document of the latest
+// strip in the database.
+func (s *Server) latest(repo database.Repository, r *http.Request) (interface{}, error) {
+	id, err := repo.LatestID()
+	if err != nil {
+		return nil, err
+	}
+	strip, err := repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if strip == nil {
+		return nil, notFound("the database is empty")
+	}
+	return output.NewStrip(strip), nil
+}
+
+// Health is the state of the database, returned by GET /healthz.
+type Health struct {
+	Status string `json:"status"`
+	Strips int    `json:"strips"`
+	Latest int    `json:"latest"`
+}
+
+// healthz answers GET /healthz. Not being able to open the database makes
+// it fail in withRepo.
+func (s *Server) healthz(repo database.Repository, r *http.Request) (interface{}, error) {
+	count, err := repo.Count()
+	if err != nil {
+		return nil, err
+	}
+	latest, err := repo.LatestID()
+	if err != nil {
+		return nil, err
+	}
+	return &Health{Status: "ok", Strips: count, Latest: latest}, nil
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lavagetto/xkcli/database"
+	"github.com/lavagetto/xkcli/output"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func testServer() (*Server, *observer.ObservedLogs) {
+	repo := database.NewMemoryRepository()
+	repo.Put(&database.XKCDStrip{ID: 45, Title: "Schrodinger", Date: "2006-01-18", Comment: "I want to believe the cat is alive"})
+	repo.Put(&database.XKCDStrip{ID: 327, Title: "Exploits of a Mom", Date: "2007-10-10", Tags: []string{"sql"},
+		Comment: "Her daughter is named Help I'm trapped in a driver's license factory."})
+	repo.Put(&database.XKCDStrip{ID: 1171, Title: "Perl Problems", Date: "2013-02-06", Transcript: "I got 99 problems, so I used regular expressions."})
+	core, logs := observer.New(zap.InfoLevel)
+	opts := &Options{
+		Search:        database.SearchOpts{MinScore: 0.1},
+		FuzzyFallback: 1,
+		FuzzyMinScore: 0.1,
+		MaxLimit:      50,
+	}
+	open := func() (database.Repository, error) { return repo, nil }
+	return New(open, opts, zap.New(core).Sugar()), logs
+}
+
+// get sends a request to the server, and decodes the answer into doc.
+func get(t *testing.T, s *Server, url string, doc interface{}) int {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(doc))
+	return rec.Code
+}
+
+func TestSearch(t *testing.T) {
+	s, logs := testServer()
+	var doc output.Search
+	assert.Equal(t, http.StatusOK, get(t, s, "/search?q=problems", &doc))
+	assert.Equal(t, "problems", doc.Query)
+	assert.Len(t, doc.Results, 1)
+	assert.Equal(t, 1171, doc.Results[0].Strip.ID)
+	assert.Equal(t, 1, doc.Results[0].Rank)
+	assert.False(t, doc.Fuzzy)
+
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "/search?q=problems", fields["path"])
+	assert.Equal(t, int64(http.StatusOK), fields["status"])
+
+	doc = output.Search{}
+	// The memory repository doesn't do fuzzy searches, but the fallback is tried.
+	assert.Equal(t, http.StatusOK, get(t, s, "/search?q=problemz", &doc))
+	assert.True(t, doc.Fuzzy)
+
+	doc = output.Search{}
+	get(t, s, "/search?q=i&since=2007&limit=1", &doc)
+	assert.Equal(t, 2, doc.Above)
+	assert.Len(t, doc.Results, 1)
+	doc = output.Search{}
+	get(t, s, "/search?q=i&since=2007&limit=1&offset=1", &doc)
+	assert.Len(t, doc.Results, 1)
+	assert.Equal(t, 2, doc.Results[0].Rank)
+}
+
+func TestSearchErrors(t *testing.T) {
+	s, _ := testServer()
+	for url, message := range map[string]string{
+		"/search":                   "the q parameter is required",
+		"/search?q=cat&limit=x":     `invalid limit "x": it must be a number`,
+		"/search?q=cat&limit=0":     "the limit must be positive",
+		"/search?q=cat&limit=51":    "the limit can't be more than 50",
+		"/search?q=cat&offset=-1":   "the offset can't be negative",
+		"/search?q=cat&since=never": "invalid since",
+		"/search?q=%22cat":          "",
+	} {
+		var doc map[string]string
+		assert.Equal(t, http.StatusBadRequest, get(t, s, url, &doc), url)
+		assert.Contains(t, doc["error"], message, url)
+	}
+}
+
+func TestStrips(t *testing.T) {
+	s, _ := testServer()
+	var strip output.Strip
+	assert.Equal(t, http.StatusOK, get(t, s, "/strips/327", &strip))
+	assert.Equal(t, "Exploits of a Mom", strip.Title)
+	assert.Equal(t, "https://xkcd.com/327", strip.URL)
+	assert.Equal(t, []string{"sql"}, strip.Tags)
+
+	var doc map[string]string
+	assert.Equal(t, http.StatusNotFound, get(t, s, "/strips/328", &doc))
+	assert.Equal(t, "strip 328 is not in the database", doc["error"])
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/strips/bobby", &doc))
+	assert.Equal(t, http.StatusNotFound, get(t, s, "/nothing", &doc))
+
+	strip = output.Strip{}
+	assert.Equal(t, http.StatusOK, get(t, s, "/latest", &strip))
+	assert.Equal(t, 1171, strip.ID)
+}
+
+func TestRandom(t *testing.T) {
+	s, _ := testServer()
+	var strip output.Strip
+	assert.Equal(t, http.StatusOK, get(t, s, "/random", &strip))
+	assert.Contains(t, []int{45, 327, 1171}, strip.ID)
+	strip = output.Strip{}
+	get(t, s, "/random?tag=sql", &strip)
+	assert.Equal(t, 327, strip.ID)
+	strip = output.Strip{}
+	get(t, s, "/random?q=cat&until=2006", &strip)
+	assert.Equal(t, 45, strip.ID)
+
+	var doc map[string]string
+	assert.Equal(t, http.StatusNotFound, get(t, s, "/random?tag=missing", &doc))
+	assert.Equal(t, "no strip matches the filters", doc["error"])
+}
+
+func TestHealthz(t *testing.T) {
+	s, _ := testServer()
+	var health Health
+	assert.Equal(t, http.StatusOK, get(t, s, "/healthz", &health))
+	assert.Equal(t, Health{Status: "ok", Strips: 3, Latest: 1171}, health)
+
+	s.open = func() (database.Repository, error) { return nil, database.ErrLocked }
+	var doc map[string]string
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, s, "/healthz", &doc))
+	assert.Equal(t, database.ErrLocked.Error(), doc["error"])
+}
+
+func TestMethods(t *testing.T) {
+	s, _ := testServer()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/search?q=cat", strings.NewReader("")))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD", rec.Header().Get("Allow"))
+}
+
+func TestErrorStatus(t *testing.T) {
+	s, _ := testServer()
+	h := s.withRepo(func(database.Repository, *http.Request) (interface{}, error) {
+		return nil, errors.New("broken")
+	})
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}